@@ -0,0 +1,26 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// CachePolicy configures the caching headers sent along with the JSON listings.
+type CachePolicy struct {
+	// MaxAge is how many seconds clients may cache a response. 0 sends no caching headers.
+	MaxAge int
+}
+
+// ResponseCachePolicy is the CachePolicy of the puppies listings.
+var ResponseCachePolicy = CachePolicy{}
+
+// Apply sets the Cache-Control and Expires headers of the policy on the response.
+func (p CachePolicy) Apply(w http.ResponseWriter) {
+	if p.MaxAge <= 0 {
+		return
+	}
+
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", p.MaxAge))
+	w.Header().Set("Expires", time.Now().Add(time.Duration(p.MaxAge)*time.Second).UTC().Format(http.TimeFormat))
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestCachePolicyHeaders(t *testing.T) {
+	m := newTestManager(t)
+	m.InsertPuppies([]*Image{{ID: "1"}})
+
+	defer func(policy CachePolicy) { ResponseCachePolicy = policy }(ResponseCachePolicy)
+	ResponseCachePolicy = CachePolicy{MaxAge: 300}
+
+	w := httptest.NewRecorder()
+	ListTopPuppies(w, mux.SetURLVars(httptest.NewRequest("GET", "/top/1", nil), map[string]string{"page": "1"}))
+
+	if got := w.Header().Get("Cache-Control"); got != "public, max-age=300" {
+		t.Errorf("Cache-Control = %q, want public, max-age=300", got)
+	}
+	expires, err := http.ParseTime(w.Header().Get("Expires"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Until(expires); d < 298*time.Second || d > 300*time.Second {
+		t.Errorf("Expires in %v, want in 300s", d)
+	}
+}
+
+func TestCachePolicyDisabled(t *testing.T) {
+	w := httptest.NewRecorder()
+	CachePolicy{}.Apply(w)
+
+	for _, header := range []string{"Cache-Control", "Expires"} {
+		if got := w.Header().Get(header); got != "" {
+			t.Errorf("%s = %q, want no caching headers without a max age", header, got)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"io"
+)
+
+// The layout of the LeaderboardChart, in pixels.
+const (
+	chartWidth    = 400
+	chartBar      = 20
+	chartGap      = 4
+	chartMinWidth = 2
+)
+
+var (
+	chartBackground = color.White
+	chartPositive   = color.RGBA{0x4c, 0xaf, 0x50, 0xff}
+	chartNegative   = color.RGBA{0xe5, 0x39, 0x35, 0xff}
+)
+
+// LeaderboardChart writes a PNG horizontal bar chart of the net scores of the topN highest-scored images,
+// best first: green bars for positive scores and red ones for negative scores, as long as their absolute value
+// relative to the largest one. It fails when there is no image.
+func (m *ImageManager) LeaderboardChart(w io.Writer, topN int) error {
+	top := m.Leaderboard(topN, 0)
+	if len(top) == 0 {
+		return errors.New("no puppies to chart")
+	}
+
+	largest := 1
+	for _, im := range top {
+		if s := abs(im.Score()); s > largest {
+			largest = s
+		}
+	}
+
+	height := len(top)*(chartBar+chartGap) + chartGap
+	img := image.NewRGBA(image.Rect(0, 0, chartWidth, height))
+	draw.Draw(img, img.Bounds(), image.NewUniform(chartBackground), image.Point{}, draw.Src)
+
+	for i, im := range top {
+		width := abs(im.Score()) * (chartWidth - 2*chartGap) / largest
+		if width < chartMinWidth {
+			width = chartMinWidth
+		}
+
+		fill := chartPositive
+		if im.Score() < 0 {
+			fill = chartNegative
+		}
+
+		y := chartGap + i*(chartBar+chartGap)
+		bar := image.Rect(chartGap, y, chartGap+width, y+chartBar)
+		draw.Draw(img, bar, image.NewUniform(fill), image.Point{}, draw.Src)
+	}
+
+	return png.Encode(w, img)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestLeaderboardChart(t *testing.T) {
+	m := newCatalog(t, &Image{ID: "1", UpVotes: 10}, &Image{ID: "2", UpVotes: 5}, &Image{ID: "3", DownVotes: 10})
+
+	var buf bytes.Buffer
+	if err := m.LeaderboardChart(&buf, 3); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b := img.Bounds(); b.Dx() != chartWidth || b.Dy() != 3*(chartBar+chartGap)+chartGap {
+		t.Fatalf("chart is %dx%d, want three bars", b.Dx(), b.Dy())
+	}
+
+	full := chartWidth - 2*chartGap
+	bars := []struct {
+		fill  color.Color
+		width int
+	}{
+		{chartPositive, full},
+		{chartPositive, full / 2},
+		{chartNegative, full},
+	}
+	for i, bar := range bars {
+		y := chartGap + i*(chartBar+chartGap) + chartBar/2
+		if got := color.RGBAModel.Convert(img.At(chartGap, y)); got != bar.fill {
+			t.Errorf("bar %d starts with %v, want %v", i, got, bar.fill)
+		}
+		if got := color.RGBAModel.Convert(img.At(chartGap+bar.width-1, y)); got != bar.fill {
+			t.Errorf("bar %d ends with %v at %d pixels, want %v", i, got, bar.width, bar.fill)
+		}
+		if got := color.RGBAModel.Convert(img.At(chartGap+bar.width, y)); got == bar.fill {
+			t.Errorf("bar %d goes past %d pixels", i, bar.width)
+		}
+	}
+}
+
+func TestLeaderboardChartEmpty(t *testing.T) {
+	if err := NewImageManager().LeaderboardChart(&bytes.Buffer{}, 3); err == nil {
+		t.Error("charted an empty catalog")
+	}
+}
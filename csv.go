@@ -0,0 +1,113 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// ImportCSV sets the vote counts of the images from CSV rows of id, up_votes and down_votes, in memory and,
+// all at once, in the VoteStore when the ImageManager has one. A leading header row is skipped.
+// Rows that are malformed or name an unknown image are skipped, and reported together in the returned error.
+// It returns how many rows were applied.
+func (m *ImageManager) ImportCSV(r io.Reader) (int, error) {
+	if m.ReadOnly() {
+		return 0, ErrReadOnly
+	}
+
+	m.lockAllVotes()
+	defer m.unlockAllVotes()
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	var counts []VoteCount
+	var errs []error
+
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %v", line, err))
+			continue
+		}
+
+		if line == 1 && len(record) > 0 && record[0] == "id" {
+			continue
+		}
+
+		if len(record) != 3 {
+			errs = append(errs, fmt.Errorf("line %d: want 3 fields, got %d", line, len(record)))
+			continue
+		}
+
+		up, upErr := strconv.Atoi(record[1])
+		down, downErr := strconv.Atoi(record[2])
+		if upErr != nil || downErr != nil {
+			errs = append(errs, fmt.Errorf("line %d: invalid vote counts %q, %q", line, record[1], record[2]))
+			continue
+		}
+
+		image, ok := m.Find(record[0])
+		if !ok {
+			errs = append(errs, fmt.Errorf("line %d: %v: %s", line, ErrImageNotFound, record[0]))
+			continue
+		}
+
+		counts = append(counts, VoteCount{image.ID, up, down})
+	}
+
+	if m.persistent() {
+		if err := m.voteStore().Set(counts...); err != nil {
+			return 0, err
+		}
+	}
+
+	for _, c := range counts {
+		m.setVotes(c.ID, c.UpVotes, c.DownVotes)
+	}
+
+	return len(counts), errors.Join(errs...)
+}
+
+// ExportVoteLogCSV writes the vote_log as CSV rows of puppy_id, direction ("up" or "down") and unix timestamp,
+// below a header row, in the order the votes were cast. Rows are streamed as they are read from the database.
+func (m *ImageManager) ExportVoteLogCSV(w io.Writer) error {
+	rows, err := m.query("select puppy_id, up_vote, created_at from vote_log order by id")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"puppy_id", "direction", "timestamp"}); err != nil {
+		return err
+	}
+
+	for rows.Next() {
+		var id string
+		var up bool
+		var createdAt int64
+		if err := rows.Scan(&id, &up, &createdAt); err != nil {
+			return err
+		}
+
+		direction := "down"
+		if up {
+			direction = "up"
+		}
+		if err := writer.Write([]string{id, direction, strconv.FormatInt(createdAt, 10)}); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImportCSV(t *testing.T) {
+	m := newTestManager(t)
+	saveImages(t, m, &Image{ID: "1", UpVotes: 1}, &Image{ID: "2"})
+	m.InsertPuppies(m.All())
+
+	n, err := m.ImportCSV(strings.NewReader("id,up_votes,down_votes\n1,7,2\n3,1,1\n2,x,1\n2,4,0\n"))
+	if n != 2 {
+		t.Errorf("ImportCSV = %d, want 2 rows applied", n)
+	}
+	if err == nil || !strings.Contains(err.Error(), "line 3") || !strings.Contains(err.Error(), "line 4") {
+		t.Errorf("ImportCSV error = %v, want the unknown and malformed rows", err)
+	}
+
+	want := map[string][2]int{"1": {7, 2}, "2": {4, 0}}
+	for _, im := range m.FindOldPuppies([]string{"1", "2"}) {
+		if got := [2]int{im.UpVotes, im.DownVotes}; got != want[im.ID] {
+			t.Errorf("stored votes of %s = %v, want %v", im.ID, got, want[im.ID])
+		}
+		if mem, _ := m.Find(im.ID); [2]int{mem.UpVotes, mem.DownVotes} != want[im.ID] {
+			t.Errorf("votes of %s in memory = %d, %d, want %v", im.ID, mem.UpVotes, mem.DownVotes, want[im.ID])
+		}
+	}
+}
+
+func TestImportCSVDuringVote(t *testing.T) {
+	store := newFakeVoteStore(&Image{ID: "1"})
+	m := NewImageManager()
+	imported := make(chan struct{})
+	m.Votes = hookStore{store, func() {
+		go func() {
+			defer close(imported)
+			if _, err := m.ImportCSV(strings.NewReader("1,100,0\n")); err != nil {
+				t.Error(err)
+			}
+		}()
+		time.Sleep(10 * time.Millisecond)
+	}}
+	saveImages(t, m, &Image{ID: "1"})
+
+	if _, _, err := m.Update(&Image{ID: "1"}, true); err != nil {
+		t.Fatal(err)
+	}
+	<-imported
+
+	im, _ := m.Find("1")
+	stored, _ := store.Load([]string{"1"})
+	if im.UpVotes != 100 || stored[0].UpVotes != 100 {
+		t.Errorf("UpVotes = %d in memory, %d stored, want the imported 100 in both", im.UpVotes, stored[0].UpVotes)
+	}
+}
+
+func TestExportVoteLogCSV(t *testing.T) {
+	m := newTestManager(t)
+	at := time.Unix(1714564800, 0)
+	logVoteAt(t, m, at, 2, true, "a")
+	logVoteAt(t, m, at.Add(time.Minute), 1, false, "b")
+	logVoteAt(t, m, at.Add(2*time.Minute), 2, false, "a")
+
+	var buf bytes.Buffer
+	if err := m.ExportVoteLogCSV(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "puppy_id,direction,timestamp\n2,up,1714564800\n1,down,1714564860\n2,down,1714564920\n"
+	if buf.String() != want {
+		t.Errorf("CSV =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// The EmptyResponseMode values.
+const (
+	// EmptyAsArray lists no image, as for any other page.
+	EmptyAsArray = "array"
+
+	// EmptyAsNotFound replies 404 Not Found with the EmptyCatalogMessage.
+	EmptyAsNotFound = "404"
+
+	// EmptyAsMessage replies a JSON object holding the EmptyCatalogMessage as its message.
+	EmptyAsMessage = "message"
+)
+
+// EmptyResponseMode selects how the listings reply when there is no puppy to list. It defaults to EmptyAsArray.
+var EmptyResponseMode = EmptyAsArray
+
+// EmptyCatalogMessage is the message replied for an empty listing in the EmptyAsNotFound and EmptyAsMessage modes.
+var EmptyCatalogMessage = "no puppies yet"
+
+// replyEmpty replies as selected by EmptyResponseMode when the catalog of the response is empty, and reports
+// whether it did. A page past the end of a non-empty catalog, like the listings in the EmptyAsArray mode,
+// is not replied to, but its images are made an empty array.
+func replyEmpty(w http.ResponseWriter, response *PuppiesResponse) bool {
+	if len(response.Images) > 0 {
+		return false
+	}
+
+	if response.Total == 0 {
+		switch EmptyResponseMode {
+		case EmptyAsNotFound:
+			http.Error(w, EmptyCatalogMessage, http.StatusNotFound)
+			return true
+		case EmptyAsMessage:
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(struct {
+				Message string `json:"message"`
+			}{EmptyCatalogMessage})
+			return true
+		}
+	}
+
+	response.Images = []*Image{}
+	return false
+}
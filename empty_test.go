@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReplyEmpty(t *testing.T) {
+	defer func(mode string) { EmptyResponseMode = mode }(EmptyResponseMode)
+
+	tests := []struct {
+		mode, body string
+		code       int
+		replied    bool
+	}{
+		{EmptyAsArray, "", http.StatusOK, false},
+		{EmptyAsNotFound, EmptyCatalogMessage, http.StatusNotFound, true},
+		{EmptyAsMessage, `{"message":"` + EmptyCatalogMessage + `"}`, http.StatusOK, true},
+	}
+
+	for _, tt := range tests {
+		EmptyResponseMode = tt.mode
+		w := httptest.NewRecorder()
+		response := &PuppiesResponse{}
+
+		if replied := replyEmpty(w, response); replied != tt.replied {
+			t.Errorf("%s: replied = %v, want %v", tt.mode, replied, tt.replied)
+		}
+		if w.Code != tt.code || strings.TrimSpace(w.Body.String()) != tt.body {
+			t.Errorf("%s: got %d %q, want %d %q", tt.mode, w.Code, w.Body, tt.code, tt.body)
+		}
+		if !tt.replied && response.Images == nil {
+			t.Errorf("%s: images left nil, want an empty array", tt.mode)
+		}
+	}
+}
+
+func TestReplyEmptyPastLastPage(t *testing.T) {
+	defer func(mode string) { EmptyResponseMode = mode }(EmptyResponseMode)
+
+	for _, mode := range []string{EmptyAsArray, EmptyAsNotFound, EmptyAsMessage} {
+		EmptyResponseMode = mode
+		w := httptest.NewRecorder()
+		response := &PuppiesResponse{Page: 99, Pages: 2, Total: 15}
+
+		if replyEmpty(w, response) {
+			t.Errorf("%s: replied for a page past the end of a non-empty catalog", mode)
+		}
+		if response.Images == nil {
+			t.Errorf("%s: images left nil, want an empty array", mode)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"hash/fnv"
+	"sort"
+	"time"
+)
+
+// FeatureUntil features the image with the given id until the given time, replacing its previous schedule.
+// It returns ErrImageNotFound for an unknown id.
+func (m *ImageManager) FeatureUntil(id string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, im := range m.images {
+		if im.ID == id {
+			if m.featured == nil {
+				m.featured = make(map[string]time.Time)
+				m.featuredCount = make(map[string]int)
+			}
+			m.featured[id] = until
+			m.featuredCount[id]++
+			return nil
+		}
+	}
+
+	return ErrImageNotFound
+}
+
+// FeaturedImages returns the images featured at the current time of the Clock, in catalog order.
+func (m *ImageManager) FeaturedImages() []*Image {
+	now := m.now()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var rs []*Image
+	for _, im := range m.images {
+		if until, ok := m.featured[im.ID]; ok && now.Before(until) {
+			rs = append(rs, cloneImage(im))
+		}
+	}
+
+	return rs
+}
+
+// NeverFeatured returns the images which were never featured, in catalog order,
+// so that the featured slot can be rotated fairly.
+func (m *ImageManager) NeverFeatured() []*Image {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var rs []*Image
+	for _, im := range m.images {
+		if m.featuredCount[im.ID] == 0 {
+			rs = append(rs, cloneImage(im))
+		}
+	}
+
+	return rs
+}
+
+// PuppyOfTheDay returns the image featured on the day of date, picked by hashing the day so that the same day
+// always gives the same puppy as long as the catalog doesn't change. It returns false for an empty catalog.
+func (m *ImageManager) PuppyOfTheDay(date time.Time) (*Image, bool) {
+	images := m.All()
+	if len(images) == 0 {
+		return nil, false
+	}
+	sort.Slice(images, func(i, j int) bool { return images[i].ID < images[j].ID })
+
+	h := fnv.New32a()
+	h.Write([]byte(date.Format("2006-01-02")))
+	return images[h.Sum32()%uint32(len(images))], true
+}
+
+// FeaturedEngagementShare returns the fraction of all the votes which went to the currently featured images,
+// or 0 when no image received any vote.
+func (m *ImageManager) FeaturedEngagementShare() float64 {
+	featured := make(map[string]bool)
+	for _, im := range m.FeaturedImages() {
+		featured[im.ID] = true
+	}
+
+	total, onFeatured := 0, 0
+	for _, im := range m.All() {
+		votes := im.UpVotes + im.DownVotes
+		total += votes
+		if featured[im.ID] {
+			onFeatured += votes
+		}
+	}
+
+	if total == 0 {
+		return 0
+	}
+	return float64(onFeatured) / float64(total)
+}
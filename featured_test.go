@@ -0,0 +1,102 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFeaturedImages(t *testing.T) {
+	m := newCatalog(t, &Image{ID: "1"}, &Image{ID: "2"}, &Image{ID: "3"})
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fixedClock(m, now)
+
+	if err := m.FeatureUntil("1", now.Add(-time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.FeatureUntil("2", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.FeatureUntil("4", now.Add(time.Hour)); err != ErrImageNotFound {
+		t.Errorf("FeatureUntil of an unknown image error = %v, want ErrImageNotFound", err)
+	}
+
+	if ids := imageIDs(m.FeaturedImages()); !reflect.DeepEqual(ids, []string{"2"}) {
+		t.Errorf("FeaturedImages = %v, want [2] only, 1 having a past window", ids)
+	}
+
+	fixedClock(m, now.Add(2*time.Hour))
+	if ids := imageIDs(m.FeaturedImages()); len(ids) != 0 {
+		t.Errorf("FeaturedImages = %v once every window ended, want none", ids)
+	}
+}
+
+func TestPuppyOfTheDay(t *testing.T) {
+	var images []*Image
+	for i := 1; i <= 10; i++ {
+		images = append(images, &Image{ID: strconv.Itoa(i)})
+	}
+	m := newCatalog(t, images...)
+	day := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+
+	first, ok := m.PuppyOfTheDay(day)
+	if !ok {
+		t.Fatal("no puppy of the day in a catalog of 10")
+	}
+	if again, _ := m.PuppyOfTheDay(day.Add(12 * time.Hour)); again.ID != first.ID {
+		t.Errorf("puppy of the day = %s later that day, want %s", again.ID, first.ID)
+	}
+	reversed := newCatalog(t)
+	for i := len(images) - 1; i >= 0; i-- {
+		saveImages(t, reversed, images[i])
+	}
+	if other, _ := reversed.PuppyOfTheDay(day); other.ID != first.ID {
+		t.Errorf("puppy of the day = %s in a catalog in another order, want %s", other.ID, first.ID)
+	}
+
+	picked := make(map[string]bool)
+	for d := 0; d < 30; d++ {
+		im, _ := m.PuppyOfTheDay(day.AddDate(0, 0, d))
+		picked[im.ID] = true
+	}
+	if len(picked) < 2 {
+		t.Errorf("puppies of 30 days = %v, want them to vary", picked)
+	}
+
+	if _, ok := NewImageManager().PuppyOfTheDay(day); ok {
+		t.Error("found a puppy of the day in an empty catalog")
+	}
+}
+
+func TestNeverFeatured(t *testing.T) {
+	m := newCatalog(t, &Image{ID: "1"}, &Image{ID: "2"}, &Image{ID: "3"})
+	now := time.Now()
+
+	if ids := imageIDs(m.NeverFeatured()); !reflect.DeepEqual(ids, []string{"1", "2", "3"}) {
+		t.Errorf("NeverFeatured = %v, want every image", ids)
+	}
+
+	m.FeatureUntil("2", now.Add(-time.Hour))
+	m.FeatureUntil("3", now.Add(time.Hour))
+	if ids := imageIDs(m.NeverFeatured()); !reflect.DeepEqual(ids, []string{"1"}) {
+		t.Errorf("NeverFeatured = %v, want [1], 2 having been featured in the past", ids)
+	}
+}
+
+func TestFeaturedEngagementShare(t *testing.T) {
+	if share := NewImageManager().FeaturedEngagementShare(); share != 0 {
+		t.Errorf("share without votes = %v, want 0", share)
+	}
+
+	m := newCatalog(t, &Image{ID: "1", UpVotes: 3, DownVotes: 1}, &Image{ID: "2", UpVotes: 4}, &Image{ID: "3", UpVotes: 8})
+	now := time.Now()
+	fixedClock(m, now)
+	m.FeatureUntil("1", now.Add(time.Hour))
+	m.FeatureUntil("2", now.Add(time.Hour))
+	m.FeatureUntil("3", now.Add(-time.Hour))
+
+	if share := m.FeaturedEngagementShare(); share != 0.5 {
+		t.Errorf("share = %v, want 8 of the 16 votes", share)
+	}
+}
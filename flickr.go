@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// searchMethods are the Flickr API methods returning a page of photos that SearchOptions may select.
+var searchMethods = map[string]bool{
+	FlickrQuery:                      true,
+	"flickr.interestingness.getList": true,
+	"flickr.photos.getRecent":        true,
+}
+
+// SearchOptions configures a Flickr photo search.
+type SearchOptions struct {
+	// Method is the Flickr API method listing the photos. It defaults to FlickrQuery.
+	Method string
+
+	Tags    string
+	Page    int
+	PerPage int
+
+	// Pages is how many pages, starting at Page, SearchPhotosMulti fetches.
+	Pages int
+
+	// MaxPages caps Pages, so that a misconfigured search can't fetch thousands of pages.
+	// It defaults to DefaultMaxPages.
+	MaxPages int
+
+	// Concurrency is how many pages SearchPhotosMulti fetches at the same time. Below 2, pages are fetched in turn.
+	Concurrency int
+
+	// Client sends the requests to Flickr. It defaults to http.DefaultClient.
+	Client *http.Client
+
+	// CacheDir, when set, is the directory where the raw Flickr responses are kept,
+	// so that identical searches are answered without calling Flickr while they are fresh.
+	CacheDir string
+
+	// CacheTTL is how long a cached response stays fresh.
+	CacheTTL time.Duration
+}
+
+// DefaultMaxPages is the default cap on the pages fetched by SearchPhotosMulti.
+const DefaultMaxPages = 20
+
+// malformedBodyPrefix is how many bytes of an unparseable body an ErrMalformedResponse keeps.
+const malformedBodyPrefix = 200
+
+// ErrMalformedResponse is returned when a Flickr response can't be parsed, e.g. because it was truncated.
+type ErrMalformedResponse struct {
+	// Err is the parse error.
+	Err error
+
+	// Body holds the first bytes of the response, for debugging.
+	Body string
+}
+
+func (e *ErrMalformedResponse) Error() string {
+	return fmt.Sprintf("malformed flickr response: %v (body: %q)", e.Err, e.Body)
+}
+
+func (e *ErrMalformedResponse) Unwrap() error {
+	return e.Err
+}
+
+// unmarshalFlickr parses the Flickr response body into v, returning an ErrMalformedResponse on failure.
+func unmarshalFlickr(body []byte, v interface{}) error {
+	if err := xml.Unmarshal(body, v); err != nil {
+		prefix := body
+		if len(prefix) > malformedBodyPrefix {
+			prefix = prefix[:malformedBodyPrefix]
+		}
+		return &ErrMalformedResponse{Err: err, Body: string(prefix)}
+	}
+	return nil
+}
+
+func (e flickrError) Error() string {
+	return "flickr error " + e.Code + ": " + e.Msg
+}
+
+// SearchPhotos searches Flickr for the photos matching the options.
+func SearchPhotos(opts SearchOptions) (*SearchResponse, error) {
+	return searchPage(context.Background(), opts)
+}
+
+// SearchPhotosMulti fetches opts.Pages pages of search results starting at opts.Page, up to opts.Concurrency at
+// a time, and returns their photos in page order. The first failure cancels the fetches still in flight.
+// It fetches no more than opts.MaxPages pages, and stops at the last page reported by Flickr in the first one.
+func SearchPhotosMulti(ctx context.Context, opts SearchOptions) ([]Photo, error) {
+	concurrency := opts.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	maxPages := opts.MaxPages
+	if maxPages < 1 {
+		maxPages = DefaultMaxPages
+	}
+	pages := opts.Pages
+	if pages > maxPages {
+		pages = maxPages
+	}
+	if pages < 1 {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	first, err := searchPage(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	if reported, err := atoiOrZero(first.Pages); err == nil && reported > 0 {
+		if left := reported - opts.Page + 1; left < pages {
+			pages = left
+		}
+		if pages < 1 {
+			pages = 1
+		}
+	}
+
+	results := make([][]Photo, pages)
+	results[0] = first.Photos
+	var firstErr error
+	var once sync.Once
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, concurrency)
+
+	for i := 1; i < pages; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			pageOpts := opts
+			pageOpts.Page = opts.Page + i
+			resp, err := searchPage(ctx, pageOpts)
+			if err != nil {
+				once.Do(func() {
+					firstErr = err
+					cancel()
+				})
+				return
+			}
+			results[i] = resp.Photos
+		}(i)
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var photos []Photo
+	for _, page := range results {
+		photos = append(photos, page...)
+	}
+	return photos, nil
+}
+
+// searchPage fetches the page of search results selected by the options.
+func searchPage(ctx context.Context, opts SearchOptions) (*SearchResponse, error) {
+	method := opts.Method
+	if method == "" {
+		method = FlickrQuery
+	}
+	if !searchMethods[method] {
+		return nil, fmt.Errorf("unsupported flickr method %q", method)
+	}
+
+	baseUrl, err := url.Parse(FlickrEndPoint)
+	if err != nil {
+		return nil, err
+	}
+
+	params := url.Values{}
+	params.Add("method", method)
+	params.Add("api_key", FlickrKey)
+	params.Add("tags", opts.Tags)
+	params.Add("per_page", strconv.Itoa(opts.PerPage))
+	params.Add("page", strconv.Itoa(opts.Page))
+	params.Add("safe_search", "2")
+	params.Add("sort", "date-posted-desc")
+	params.Add("extras", "geo,o_dims")
+
+	baseUrl.RawQuery = params.Encode()
+
+	body, cached := readCachedSearch(opts, baseUrl.String())
+	if !cached {
+		client := opts.Client
+		if client == nil {
+			client = http.DefaultClient
+		}
+
+		req, err := http.NewRequestWithContext(ctx, "GET", baseUrl.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+
+		body, err = ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	flickrResponse := struct {
+		Stat   string         `xml:"stat,attr"`
+		Err    flickrError    `xml:"err"`
+		Photos SearchResponse `xml:"photos"`
+	}{}
+
+	if err := unmarshalFlickr(body, &flickrResponse); err != nil {
+		return nil, err
+	}
+
+	if flickrResponse.Stat != "ok" {
+		return nil, flickrResponse.Err
+	}
+
+	if !cached {
+		writeCachedSearch(opts, baseUrl.String(), body)
+	}
+
+	return &flickrResponse.Photos, nil
+}
+
+// searchCachePath returns the file caching the response to the search query.
+func searchCachePath(opts SearchOptions, query string) string {
+	sum := sha1.Sum([]byte(query))
+	return filepath.Join(opts.CacheDir, hex.EncodeToString(sum[:])+".xml")
+}
+
+// readCachedSearch returns the cached response to the search query, if there is a fresh one.
+func readCachedSearch(opts SearchOptions, query string) ([]byte, bool) {
+	if opts.CacheDir == "" {
+		return nil, false
+	}
+
+	path := searchCachePath(opts, query)
+	info, err := os.Stat(path)
+	if err != nil || time.Since(info.ModTime()) > opts.CacheTTL {
+		return nil, false
+	}
+
+	body, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	return body, true
+}
+
+// writeCachedSearch stores the response to the search query in the cache. Failures only cost a cache miss.
+func writeCachedSearch(opts SearchOptions, query string, body []byte) {
+	if opts.CacheDir == "" {
+		return
+	}
+
+	if err := os.MkdirAll(opts.CacheDir, 0755); err != nil {
+		return
+	}
+	ioutil.WriteFile(searchCachePath(opts, query), body, 0644)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// roundTripFunc is an http.RoundTripper calling itself.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// stubClient returns a client answering every request, whatever its host, with h.
+func stubClient(h http.Handler) *http.Client {
+	return &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Result(), nil
+	})}
+}
+
+// flickrSearchStub is a Flickr search API with pages pages of two photos, ids p<page>-<n>, counting its calls.
+type flickrSearchStub struct {
+	pages int
+	calls int32
+}
+
+func (s *flickrSearchStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	atomic.AddInt32(&s.calls, 1)
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+
+	fmt.Fprintf(w, `<rsp stat="ok"><photos page="%d" pages="%d" perpage="2" total="%d">`, page, s.pages, 2*s.pages)
+	for n := 1; n <= 2; n++ {
+		fmt.Fprintf(w, `<photo id="p%d-%d" owner="o" secret="s" server="1" farm="1" title="Puppy %d-%d"/>`, page, n, page, n)
+	}
+	fmt.Fprint(w, `</photos></rsp>`)
+}
+
+func TestSearchPhotosCached(t *testing.T) {
+	stub := &flickrSearchStub{pages: 1}
+	opts := SearchOptions{Tags: "puppy", Page: 1, PerPage: 2, Client: stubClient(stub), CacheDir: t.TempDir(), CacheTTL: time.Hour}
+
+	first, err := SearchPhotos(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := SearchPhotos(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stub.calls != 1 {
+		t.Errorf("flickr called %d times, want the second search served from the cache", stub.calls)
+	}
+	if len(second.Photos) != len(first.Photos) || second.Photos[0].ID != first.Photos[0].ID {
+		t.Errorf("cached photos = %v, want %v", second.Photos, first.Photos)
+	}
+
+	opts.Tags = "kitten"
+	if _, err := SearchPhotos(opts); err != nil {
+		t.Fatal(err)
+	}
+	if stub.calls != 2 {
+		t.Errorf("flickr called %d times, want a different search not served from the cache", stub.calls)
+	}
+}
+
+func TestSearchPhotosMultiConcurrent(t *testing.T) {
+	stub := &flickrSearchStub{pages: 5}
+	opts := SearchOptions{Tags: "puppy", Page: 1, Pages: 5, PerPage: 2, Concurrency: 3, Client: stubClient(stub)}
+
+	photos, err := SearchPhotosMulti(context.Background(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stub.calls != 5 {
+		t.Errorf("flickr called %d times, want every page fetched once", stub.calls)
+	}
+	var ids []string
+	for _, ph := range photos {
+		ids = append(ids, ph.ID)
+	}
+	want := []string{"p1-1", "p1-2", "p2-1", "p2-2", "p3-1", "p3-2", "p4-1", "p4-2", "p5-1", "p5-2"}
+	if !reflect.DeepEqual(ids, want) {
+		t.Errorf("photos = %v, want %v", ids, want)
+	}
+}
+
+func TestSearchPhotosMultiError(t *testing.T) {
+	stub := &flickrSearchStub{pages: 5}
+	client := stubClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "3" {
+			fmt.Fprint(w, `<rsp stat="fail"><err code="105" msg="Service currently unavailable"/></rsp>`)
+			return
+		}
+		stub.ServeHTTP(w, r)
+	}))
+	opts := SearchOptions{Tags: "puppy", Page: 1, Pages: 5, PerPage: 2, Concurrency: 2, Client: client}
+
+	if photos, err := SearchPhotosMulti(context.Background(), opts); err == nil {
+		t.Errorf("SearchPhotosMulti = %d photos, want the error of page 3", len(photos))
+	}
+}
+
+func TestSearchPhotosMalformed(t *testing.T) {
+	body := `<rsp stat="ok"><photos page="1" pages="1"><photo id="1" title="` + strings.Repeat("Rex ", 100)
+	client := stubClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { fmt.Fprint(w, body) }))
+
+	_, err := SearchPhotos(SearchOptions{Tags: "puppy", Page: 1, PerPage: 2, Client: client})
+
+	var malformed *ErrMalformedResponse
+	if !errors.As(err, &malformed) {
+		t.Fatalf("SearchPhotos error = %v, want an ErrMalformedResponse", err)
+	}
+	if malformed.Err == nil || malformed.Body != body[:malformedBodyPrefix] {
+		t.Errorf("ErrMalformedResponse = %+v, want the parse error and the start of the body", malformed)
+	}
+}
+
+func TestSearchPhotosMethod(t *testing.T) {
+	stub := &flickrSearchStub{pages: 1}
+	var methods []string
+	client := stubClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.URL.Query().Get("method"))
+		stub.ServeHTTP(w, r)
+	}))
+
+	for _, method := range []string{"", "flickr.interestingness.getList"} {
+		if _, err := SearchPhotos(SearchOptions{Tags: "puppy", Page: 1, PerPage: 2, Method: method, Client: client}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{FlickrQuery, "flickr.interestingness.getList"}; !reflect.DeepEqual(methods, want) {
+		t.Errorf("methods = %v, want %v", methods, want)
+	}
+
+	if _, err := SearchPhotos(SearchOptions{Tags: "puppy", Page: 1, Method: "flickr.people.delete", Client: client}); err == nil {
+		t.Error("SearchPhotos accepted an unknown method")
+	}
+	if len(methods) != 2 {
+		t.Errorf("flickr called %d times, want no request with an unknown method", len(methods))
+	}
+}
+
+func TestSearchPhotosMultiPageCap(t *testing.T) {
+	tests := []struct {
+		reported, pages, maxPages int
+		want                      int32
+	}{
+		{reported: 50, pages: 1000, maxPages: 4, want: 4},
+		{reported: 3, pages: 1000, maxPages: 10, want: 3},
+		{reported: 50, pages: 1000, want: DefaultMaxPages},
+		{reported: 50, pages: 2, maxPages: 10, want: 2},
+	}
+	for _, tt := range tests {
+		stub := &flickrSearchStub{pages: tt.reported}
+		opts := SearchOptions{Tags: "puppy", Page: 1, Pages: tt.pages, MaxPages: tt.maxPages, PerPage: 2, Concurrency: 4, Client: stubClient(stub)}
+
+		photos, err := SearchPhotosMulti(context.Background(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stub.calls != tt.want || len(photos) != 2*int(tt.want) {
+			t.Errorf("%+v: %d pages fetched, %d photos, want %d pages", tt, stub.calls, len(photos), tt.want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+)
+
+type geoJSONGeometry struct {
+	Type        string     `json:"type"`
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+type geoJSONFeature struct {
+	Type       string            `json:"type"`
+	Geometry   geoJSONGeometry   `json:"geometry"`
+	Properties map[string]string `json:"properties"`
+}
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+// hasGeo reports whether the image is geotagged.
+func (i *Image) hasGeo() bool {
+	return i.Latitude != 0 || i.Longitude != 0
+}
+
+// GeoJSON writes the geotagged images as a GeoJSON FeatureCollection of points,
+// with the id, title and thumbnail of every image in its properties.
+func (m *ImageManager) GeoJSON(w io.Writer) error {
+	collection := geoJSONFeatureCollection{Type: "FeatureCollection", Features: []geoJSONFeature{}}
+	for _, im := range m.All() {
+		if !im.hasGeo() {
+			continue
+		}
+
+		collection.Features = append(collection.Features, geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geoJSONGeometry{"Point", [2]float64{im.Longitude, im.Latitude}},
+			Properties: map[string]string{
+				"id":        im.ID,
+				"title":     im.Title,
+				"thumbnail": im.Thumbnail,
+			},
+		})
+	}
+
+	return json.NewEncoder(w).Encode(collection)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestGeoJSON(t *testing.T) {
+	m := newCatalog(t, &Image{ID: "1", Title: "Rex", Thumbnail: "https://example.com/rex.jpg", Latitude: 48.85, Longitude: 2.35},
+		&Image{ID: "2", Title: "Fido"}, &Image{ID: "3", Title: "Max", Latitude: -33.87, Longitude: 151.21})
+
+	var buf bytes.Buffer
+	if err := m.GeoJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	var collection struct {
+		Type     string
+		Features []struct {
+			Type     string
+			Geometry struct {
+				Type        string
+				Coordinates []float64
+			}
+			Properties map[string]string
+		}
+	}
+	if err := json.Unmarshal(buf.Bytes(), &collection); err != nil {
+		t.Fatalf("%v: %s", err, buf.String())
+	}
+
+	if collection.Type != "FeatureCollection" || len(collection.Features) != 2 {
+		t.Fatalf("GeoJSON = %s, want a FeatureCollection of 2 features", buf.String())
+	}
+	rex := collection.Features[0]
+	if rex.Type != "Feature" || rex.Geometry.Type != "Point" || len(rex.Geometry.Coordinates) != 2 ||
+		rex.Geometry.Coordinates[0] != 2.35 || rex.Geometry.Coordinates[1] != 48.85 {
+		t.Errorf("feature = %+v, want a point at longitude 2.35, latitude 48.85", rex)
+	}
+	if want := map[string]string{"id": "1", "title": "Rex", "thumbnail": "https://example.com/rex.jpg"}; !reflect.DeepEqual(rex.Properties, want) {
+		t.Errorf("properties = %v, want %v", rex.Properties, want)
+	}
+	if collection.Features[1].Properties["id"] != "3" {
+		t.Errorf("second feature = %+v, want 3", collection.Features[1])
+	}
+}
+
+func TestGeoJSONEmpty(t *testing.T) {
+	var buf bytes.Buffer
+	if err := newCatalog(t, &Image{ID: "1"}).GeoJSON(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != `{"type":"FeatureCollection","features":[]}`+"\n" {
+		t.Errorf("GeoJSON = %s, want an empty features array", got)
+	}
+}
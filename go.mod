@@ -0,0 +1,8 @@
+module github.com/louis83/go-angular
+
+go 1.24
+
+require (
+	github.com/gorilla/mux v1.8.1
+	github.com/mattn/go-sqlite3 v1.14.22
+)
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+)
+
+// GridPerPage is the number of thumbnails on a page of the grid.
+const GridPerPage = 20
+
+var gridTemplate = template.Must(template.New("grid").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Puppies</title>
+<style>
+.grid { display: grid; grid-template-columns: repeat(auto-fill, minmax(120px, 1fr)); gap: 1em; }
+figure { margin: 0; }
+img { width: 100%; }
+</style>
+</head>
+<body>
+<div class="grid">
+{{range .Images}}<figure>
+<img src="{{.Thumbnail}}" alt="{{.Title}}">
+<figcaption>{{.Title}} &#9650;{{.UpVotes}} &#9660;{{.DownVotes}}</figcaption>
+</figure>
+{{end}}</div>
+<nav>
+{{if gt .Page 1}}<a href="?page={{.Prev}}">Previous</a>{{end}}
+{{if lt .Page .Pages}}<a href="?page={{.Next}}">Next</a>{{end}}
+</nav>
+</body>
+</html>
+`))
+
+// GridHandler renders a page of the stored puppies as an HTML grid of thumbnails with their votes.
+// The page is selected by the page query parameter.
+func GridHandler(w http.ResponseWriter, r *http.Request) error {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	imageManager, err := openImageManager()
+	if err != nil {
+		return err
+	}
+	defer imageManager.GetDB().Close()
+
+	response := imageManager.SnapshotResponse(page, GridPerPage)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return gridTemplate.Execute(w, struct {
+		*PuppiesResponse
+		Prev, Next int
+	}{response, page - 1, page + 1})
+}
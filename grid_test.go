@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSnapshotResponseHidesExpired(t *testing.T) {
+	m := NewImageManager()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	m.Clock = func() time.Time { return now }
+	saveImages(t, m, &Image{ID: "1"}, &Image{ID: "2"}, &Image{ID: "3"})
+	m.SetExpiry("2", now.Add(-time.Minute))
+	m.SetExpiry("3", now.Add(time.Minute))
+
+	response := m.SnapshotResponse(1, 10)
+	if ids := imageIDs(response.Images); len(ids) != 2 || ids[0] != "1" || ids[1] != "3" {
+		t.Errorf("images = %v, want [1 3]", ids)
+	}
+	if response.Total != 2 || response.Pages != 1 {
+		t.Errorf("total = %d, pages = %d, want the visible images only", response.Total, response.Pages)
+	}
+}
+
+func TestSnapshotResponseConcurrentVotes(t *testing.T) {
+	m := NewImageManager()
+	saveImages(t, m, &Image{ID: "1"}, &Image{ID: "2"})
+
+	const votes = 200
+	var wg sync.WaitGroup
+	for _, id := range []string{"1", "2"} {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			for i := 0; i < votes; i++ {
+				if _, _, err := m.Update(&Image{ID: id}, true); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(id)
+	}
+
+	last := 0
+	for i := 0; i < votes; i++ {
+		response := m.SnapshotResponse(1, 10)
+		if len(response.Images) != 2 {
+			t.Fatalf("images = %v, want [1 2]", imageIDs(response.Images))
+		}
+		// each snapshot copies the counts at once, so they never go back nor past the votes cast
+		up := response.Images[0].UpVotes
+		if up < last || up > votes {
+			t.Errorf("snapshot %d: up votes = %d after %d", i, up, last)
+		}
+		last = up
+	}
+	wg.Wait()
+
+	if response := m.SnapshotResponse(1, 10); response.Images[0].UpVotes != votes || response.Images[1].UpVotes != votes {
+		t.Errorf("up votes = %d, %d, want %d", response.Images[0].UpVotes, response.Images[1].UpVotes, votes)
+	}
+}
+
+func TestGridHandler(t *testing.T) {
+	m := newTestManager(t)
+	m.InsertPuppies([]*Image{{ID: "1", Title: "Rex", Thumbnail: "https://example.com/rex.jpg"}})
+
+	w := httptest.NewRecorder()
+	if err := GridHandler(w, httptest.NewRequest("GET", GridPath, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(w.Body.String(), "https://example.com/rex.jpg") {
+		t.Errorf("grid = %s, want the thumbnail of Rex", w.Body)
+	}
+}
+
+func TestGridHandlerPages(t *testing.T) {
+	m := newTestManager(t)
+	var images []*Image
+	for i := 1; i <= GridPerPage+5; i++ {
+		images = append(images, &Image{ID: strconv.Itoa(i), Title: "<b>Rex</b>", Thumbnail: "https://example.com/rex.jpg"})
+	}
+	m.InsertPuppies(images)
+
+	for page, want := range map[string]int{"1": GridPerPage, "2": 5, "3": 0} {
+		w := httptest.NewRecorder()
+		if err := GridHandler(w, httptest.NewRequest("GET", GridPath+"?page="+page, nil)); err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(w.Body.String(), "<img "); n != want {
+			t.Errorf("page %s has %d <img> tags, want %d", page, n, want)
+		}
+		if want > 0 && !strings.Contains(w.Body.String(), "&lt;b&gt;Rex") || strings.Contains(w.Body.String(), "<b>") {
+			t.Errorf("page %s = %s, want the titles escaped", page, w.Body)
+		}
+	}
+}
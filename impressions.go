@@ -0,0 +1,108 @@
+package main
+
+import "sort"
+
+// RecordImpressions adds an impression to every image with the given id, first in the VoteStore when the
+// ImageManager has one, and then in memory under a single lock, so that readers never wait on the store.
+func (m *ImageManager) RecordImpressions(ids []string) error {
+	if m.persistent() {
+		if err := m.voteStore().AddImpressions(ids); err != nil {
+			return err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range ids {
+		for _, im := range m.images {
+			if im.ID == id {
+				im.Impressions++
+			}
+		}
+	}
+
+	return nil
+}
+
+// VoteThroughRate returns the votes received by the image divided by its impressions,
+// or 0 when it was never served. It returns ErrImageNotFound for an unknown id.
+func (m *ImageManager) VoteThroughRate(id string) (float64, error) {
+	image, ok := m.Find(id)
+	if !ok {
+		return 0, ErrImageNotFound
+	}
+
+	if image.Impressions == 0 {
+		return 0, nil
+	}
+
+	return float64(image.UpVotes+image.DownVotes) / float64(image.Impressions), nil
+}
+
+// DefaultFairInterleave is the FairInterleave used when the ImageManager doesn't set one.
+const DefaultFairInterleave = 2
+
+// FairOrder returns n images alternating FairInterleave images of highest score with the image shown the least,
+// so that new puppies get seen despite their low scores.
+func (m *ImageManager) FairOrder(n int) []*Image {
+	ratio := m.FairInterleave
+	if ratio < 1 {
+		ratio = DefaultFairInterleave
+	}
+
+	images := m.All()
+	byScore := m.sortByScore(images)
+	byImpressions := m.sortByScore(images)
+	sort.SliceStable(byImpressions, func(i, j int) bool {
+		return byImpressions[i].Impressions < byImpressions[j].Impressions
+	})
+
+	used := make(map[string]bool)
+	next := func(images []*Image) *Image {
+		for _, im := range images {
+			if !used[im.ID] {
+				used[im.ID] = true
+				return im
+			}
+		}
+		return nil
+	}
+
+	var rs []*Image
+	for {
+		for i := 0; i < ratio; i++ {
+			if im := next(byScore); im != nil {
+				rs = append(rs, im)
+			}
+		}
+
+		im := next(byImpressions)
+		if im == nil {
+			break
+		}
+		rs = append(rs, im)
+	}
+
+	return firstN(rs, n)
+}
+
+// TopByEngagement returns the n images with the most votes per impression, so that the images turning views
+// into votes rise whatever their popularity. Images never served are left out.
+func (m *ImageManager) TopByEngagement(n int) []*Image {
+	var rs []*Image
+	for _, im := range m.sortByScore(m.All()) {
+		if im.Impressions > 0 {
+			rs = append(rs, im)
+		}
+	}
+
+	rate := func(im *Image) float64 {
+		return float64(im.UpVotes+im.DownVotes) / float64(im.Impressions)
+	}
+	sort.SliceStable(rs, func(i, j int) bool {
+		return rate(rs[i]) > rate(rs[j])
+	})
+
+	return firstN(rs, n)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRecordImpressions(t *testing.T) {
+	m := newTestManager(t)
+	saveImages(t, m, &Image{ID: "1"}, &Image{ID: "2"})
+	m.InsertPuppies(m.All())
+
+	if err := m.RecordImpressions([]string{"1", "2", "1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for id, want := range map[string]int{"1": 2, "2": 1} {
+		if im, _ := m.Find(id); im.Impressions != want {
+			t.Errorf("impressions of %s = %d, want %d", id, im.Impressions, want)
+		}
+	}
+
+	loaded := NewImageManager()
+	loaded.db = m.db
+	if err := loaded.LoadImages(); err != nil {
+		t.Fatal(err)
+	}
+	if im, _ := loaded.Find("1"); im == nil || im.Impressions != 2 {
+		t.Errorf("stored impressions of 1 = %v, want 2", im)
+	}
+}
+
+func TestRecordImpressionsDoesNotBlockReaders(t *testing.T) {
+	m, _ := newSlowManager(t, 0)
+	saveImages(t, m, &Image{ID: "1"})
+
+	done := make(chan error, 1)
+	go func() { done <- m.RecordImpressions([]string{"1"}) }()
+	time.Sleep(slowDelay / 4)
+
+	read := make(chan struct{})
+	go func() {
+		m.All()
+		close(read)
+	}()
+
+	select {
+	case <-read:
+	case <-done:
+		t.Fatal("All waited for the database write of RecordImpressions")
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if im, _ := m.Find("1"); im.Impressions != 1 {
+		t.Errorf("impressions = %d, want 1", im.Impressions)
+	}
+}
+
+func TestVoteThroughRate(t *testing.T) {
+	m := NewImageManager()
+	saveImages(t, m, &Image{ID: "1", UpVotes: 3, DownVotes: 1, Impressions: 8}, &Image{ID: "2", UpVotes: 2})
+
+	if rate, err := m.VoteThroughRate("1"); err != nil || rate != 0.5 {
+		t.Errorf("rate = %v, %v, want 0.5", rate, err)
+	}
+	if rate, err := m.VoteThroughRate("2"); err != nil || rate != 0 {
+		t.Errorf("rate without impressions = %v, %v, want 0", rate, err)
+	}
+	if _, err := m.VoteThroughRate("3"); err != ErrImageNotFound {
+		t.Errorf("unknown image error = %v, want ErrImageNotFound", err)
+	}
+}
+
+func TestFairOrder(t *testing.T) {
+	var images []*Image
+	for i := 1; i <= 6; i++ {
+		images = append(images, &Image{ID: strconv.Itoa(i), UpVotes: 20 - i, Impressions: 100})
+	}
+	images = append(images, &Image{ID: "new", DownVotes: 1})
+	m := newCatalog(t, images...)
+
+	if ids := imageIDs(m.FairOrder(3)); !reflect.DeepEqual(ids, []string{"1", "2", "new"}) {
+		t.Errorf("FairOrder = %v, want the new image on the first page", ids)
+	}
+
+	m.FairInterleave = 1
+	if ids := imageIDs(m.FairOrder(7)); !reflect.DeepEqual(ids, []string{"1", "new", "2", "3", "4", "5", "6"}) {
+		t.Errorf("FairOrder interleaving 1 = %v, want [1 new 2 3 4 5 6]", ids)
+	}
+}
+
+func TestTopByEngagement(t *testing.T) {
+	m := newCatalog(t, &Image{ID: "popular", UpVotes: 100, DownVotes: 20, Impressions: 10000},
+		&Image{ID: "niche", UpVotes: 4, DownVotes: 1, Impressions: 10}, &Image{ID: "unseen", UpVotes: 3})
+
+	if ids := imageIDs(m.TopByEngagement(10)); !reflect.DeepEqual(ids, []string{"niche", "popular"}) {
+		t.Errorf("TopByEngagement = %v, want [niche popular] without the image never served", ids)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Endpoint describes a route of the API.
+type Endpoint struct {
+	Path    string   `json:"path"`
+	Methods []string `json:"methods"`
+	Doc     string   `json:"doc"`
+}
+
+// apiEndpoints lists the routes registered by main, for IndexHandler. It must be kept in sync with them.
+var apiEndpoints = []Endpoint{
+	{PathPrefix, []string{"GET"}, "list the puppies found on Flickr"},
+	{PathPrefix + "/{page}", []string{"GET"}, "list a page of the puppies found on Flickr"},
+	{PathPrefix, []string{"PUT"}, "vote on a puppy"},
+	{UndoPath, []string{"POST"}, "take back a vote on a puppy"},
+	{TopPupsPrefix, []string{"GET"}, "leaderboard of the most voted puppies"},
+	{TopPupsPrefix + "/{page}", []string{"GET"}, "page of the leaderboard of the most voted puppies"},
+	{MetricsPath, []string{"GET"}, "catalog metrics"},
+	{ThumbnailPath + "/{id}", []string{"GET"}, "thumbnail of a puppy"},
+	{SitemapPath, []string{"GET"}, "sitemap of the puppy pages"},
+	{GridPath, []string{"GET"}, "HTML grid of the puppies"},
+	{LeaderboardPath, []string{"GET"}, "HTML leaderboard of the puppies"},
+	{RefreshPath, []string{"POST"}, "fetch new puppies from Flickr (admin)"},
+	{IndexPath, []string{"GET"}, "this index"},
+}
+
+// IndexHandler describes the endpoints of the API and their methods as JSON.
+// It is served under IndexPath since the root serves the web app.
+func IndexHandler(w http.ResponseWriter, r *http.Request) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(struct {
+		Endpoints []Endpoint `json:"endpoints"`
+	}{apiEndpoints})
+}
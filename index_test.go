@@ -0,0 +1,34 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestIndexHandler(t *testing.T) {
+	w := httptest.NewRecorder()
+	if err := IndexHandler(w, httptest.NewRequest("GET", IndexPath, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	var index struct{ Endpoints []Endpoint }
+	if err := json.Unmarshal(w.Body.Bytes(), &index); err != nil {
+		t.Fatalf("%v: %s", err, w.Body)
+	}
+	methods := make(map[string][]string)
+	for _, e := range index.Endpoints {
+		methods[e.Path] = append(methods[e.Path], e.Methods...)
+	}
+
+	for path, want := range map[string][]string{
+		PathPrefix:    {"GET", "PUT"},
+		TopPupsPrefix: {"GET"},
+		UndoPath:      {"POST"},
+	} {
+		if !reflect.DeepEqual(methods[path], want) {
+			t.Errorf("methods of %s = %v, want %v", path, methods[path], want)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// LeaderboardPerPage is the number of puppies on a page of the HTML leaderboard.
+const LeaderboardPerPage = 20
+
+// LeaderboardHalfLife is the half-life of the votes when the HTML leaderboard is sorted by recent votes.
+const LeaderboardHalfLife = 24 * time.Hour
+
+// leaderboardSorts maps the sorts of the HTML leaderboard to their label, in the order of the controls.
+var leaderboardSorts = []struct{ Key, Label string }{
+	{"score", "Score"},
+	{"approval", "Approval"},
+	{"recent", "Recent"},
+}
+
+var leaderboardTemplate = template.Must(template.New("leaderboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Top puppies</title>
+<style>
+.current { font-weight: bold; }
+img { width: 75px; }
+</style>
+</head>
+<body>
+<nav>Sort by:
+{{range .Sorts}}<a href="{{.Link}}"{{if .Current}} class="current"{{end}}>{{.Label}}</a>
+{{end}}</nav>
+<ol start="{{.Start}}">
+{{range .Images}}<li>
+<img src="{{.Thumbnail}}" alt="{{.Title}}">
+{{.Title}} &#9650;{{.UpVotes}} &#9660;{{.DownVotes}}
+</li>
+{{end}}</ol>
+<nav>
+{{with .Prev}}<a href="{{.}}">Previous</a>{{end}}
+{{with .Next}}<a href="{{.}}">Next</a>{{end}}
+</nav>
+</body>
+</html>
+`))
+
+// leaderboardLink returns the URL of the page of the HTML leaderboard with the given sort.
+func leaderboardLink(sort string, page int) string {
+	return "?" + url.Values{"sort": {sort}, "page": {strconv.Itoa(page)}}.Encode()
+}
+
+// LeaderboardHTMLHandler renders a page of the leaderboard as HTML, sorted by net score, by share of up votes
+// or by time-decayed net score, with controls to switch between them.
+// The sort and the page are selected by the sort and page query parameters.
+func LeaderboardHTMLHandler(w http.ResponseWriter, r *http.Request) error {
+	sortKey := r.URL.Query().Get("sort")
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	imageManager, err := openImageManager()
+	if err != nil {
+		return err
+	}
+	defer imageManager.GetDB().Close()
+
+	all := imageManager.All()
+	var ranked []*Image
+	switch sortKey {
+	case "approval":
+		ranked = imageManager.TopByRatio(len(all), 0)
+	case "recent":
+		ranked = imageManager.TopByRecency(len(all), LeaderboardHalfLife)
+	default:
+		sortKey = "score"
+		ranked = imageManager.sortByScore(all)
+	}
+
+	start := (page - 1) * LeaderboardPerPage
+	if start > len(ranked) {
+		start = len(ranked)
+	}
+	end := start + LeaderboardPerPage
+	if end > len(ranked) {
+		end = len(ranked)
+	}
+
+	type sortControl struct {
+		Label, Link string
+		Current     bool
+	}
+	data := struct {
+		Sorts      []sortControl
+		Images     []*Image
+		Start      int
+		Prev, Next string
+	}{Images: ranked[start:end], Start: start + 1}
+
+	for _, s := range leaderboardSorts {
+		data.Sorts = append(data.Sorts, sortControl{s.Label, leaderboardLink(s.Key, 1), s.Key == sortKey})
+	}
+	if page > 1 {
+		data.Prev = leaderboardLink(sortKey, page-1)
+	}
+	if end < len(ranked) {
+		data.Next = leaderboardLink(sortKey, page+1)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	return leaderboardTemplate.Execute(w, data)
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// leaderboardOrder renders the HTML leaderboard with the given query and returns it, failing unless the titles
+// appear in the given order.
+func leaderboardOrder(t *testing.T, query string, titles ...string) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	if err := LeaderboardHTMLHandler(w, httptest.NewRequest("GET", LeaderboardPath+query, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	body := w.Body.String()
+	last := -1
+	for _, title := range titles {
+		i := strings.Index(body, `alt="`+title+`"`)
+		if i <= last {
+			t.Errorf("leaderboard %s = %s, want the titles in the order %v", query, body, titles)
+			break
+		}
+		last = i
+	}
+	return body
+}
+
+func TestLeaderboardHTMLHandler(t *testing.T) {
+	m := newTestManager(t)
+	m.InsertPuppies([]*Image{{ID: "1", Title: "Rex", UpVotes: 3, DownVotes: 2}, {ID: "2", Title: "Fido", UpVotes: 2},
+		{ID: "3", Title: "Max", UpVotes: 5, DownVotes: 1}})
+
+	body := leaderboardOrder(t, "", "Max", "Fido", "Rex")
+	if !strings.Contains(body, `<a href="?page=1&amp;sort=score" class="current">Score</a>`) {
+		t.Errorf("leaderboard = %s, want the score sort selected", body)
+	}
+	if strings.Contains(body, "Next") || strings.Contains(body, "Previous") {
+		t.Errorf("leaderboard = %s, want a single page", body)
+	}
+
+	body = leaderboardOrder(t, "?sort=approval", "Fido", "Max", "Rex")
+	if !strings.Contains(body, `class="current">Approval</a>`) {
+		t.Errorf("leaderboard = %s, want the approval sort selected", body)
+	}
+}
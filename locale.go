@@ -0,0 +1,50 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// TitleTranslator translates the title of a listed image into the locale requested by the client,
+// e.g. by looking it up in externally translated titles. It defaults to returning the title unchanged.
+var TitleTranslator = func(title, locale string) string { return title }
+
+// requestLocale returns the language tag the client prefers according to the Accept-Language header of the
+// request, or an empty string when it has none.
+func requestLocale(r *http.Request) string {
+	locale := ""
+	best := 0.0
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q > best {
+			locale, best = tag, q
+		}
+	}
+
+	return locale
+}
+
+// translateTitles replaces the titles of the images by their translation into the locale of the request.
+// The images must be copies, not the ones held by an ImageManager.
+func translateTitles(r *http.Request, images []*Image) {
+	locale := requestLocale(r)
+	if locale == "" {
+		return
+	}
+
+	for _, im := range images {
+		im.Title = TitleTranslator(im.Title, locale)
+	}
+}
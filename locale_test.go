@@ -0,0 +1,57 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestRequestLocale(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"fr-FR", "fr-FR"},
+		{"en;q=0.5, fr-FR, de;q=0.8", "fr-FR"},
+		{"en;q=0.5, de;q=0.8", "de"},
+		{"*, es;q=0.1", "es"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("Accept-Language", tt.header)
+		if got := requestLocale(r); got != tt.want {
+			t.Errorf("requestLocale(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestListTopPuppiesTranslatesTitles(t *testing.T) {
+	m := newTestManager(t)
+	m.InsertPuppies([]*Image{{ID: "1", Title: "Puppy"}})
+
+	defer func(translator func(title, locale string) string) { TitleTranslator = translator }(TitleTranslator)
+	TitleTranslator = func(title, locale string) string {
+		if locale == "fr" && title == "Puppy" {
+			return "Chiot"
+		}
+		return title
+	}
+
+	for locale, want := range map[string]string{"fr": "Chiot", "de": "Puppy", "": "Puppy"} {
+		r := mux.SetURLVars(httptest.NewRequest("GET", "/top/1", nil), map[string]string{"page": "1"})
+		r.Header.Set("Accept-Language", locale)
+		w := httptest.NewRecorder()
+		ListTopPuppies(w, r)
+
+		var response PuppiesResponse
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%v: %s", err, w.Body)
+		}
+		if len(response.Images) != 1 || response.Images[0].Title != want {
+			t.Errorf("titles for %q = %v, want %s", locale, response.Images, want)
+		}
+	}
+}
@@ -3,29 +3,58 @@ package main
 
 import (
 	"encoding/json"
-	"encoding/xml"
+	"errors"
 	"github.com/gorilla/mux"
-	"io/ioutil"
 	"log"
+	"net"
 	"net/http"
 	"net/url"
 	"strconv"
+	"time"
 )
 
 const (
-	FlickrEndPoint = "https://api.flickr.com/services/rest"
-	FlickrQuery    = "flickr.photos.search"
-	FlickrKey      = "300d436fa36986e197efe2a62682e05b"
-	PathPrefix     = "/pups"
-	TopPupsPrefix  = "/top"
+	FlickrEndPoint  = "https://api.flickr.com/services/rest"
+	FlickrQuery     = "flickr.photos.search"
+	FlickrKey       = "300d436fa36986e197efe2a62682e05b"
+	PathPrefix      = "/pups"
+	TopPupsPrefix   = "/top"
+	MetricsPath     = "/metrics"
+	ThumbnailPath   = "/thumbnails"
+	SitemapPath     = "/sitemap.xml"
+	GridPath        = "/grid"
+	RefreshPath     = "/admin/refresh"
+	UndoPath        = PathPrefix + "/undo"
+	IndexPath       = "/api"
+	LeaderboardPath = "/leaderboard"
+	VoteRate        = 20
+	VoteBurst       = 100
 )
 
+// FlickrCacheDir is the directory caching the Flickr search responses across restarts. Empty disables the cache.
+var FlickrCacheDir = ""
+
+// FlickrCacheTTL is how long a cached Flickr search response is served.
+var FlickrCacheTTL = 10 * time.Minute
+
+// FlickrClient sends the requests of the handlers to Flickr.
+var FlickrClient = http.DefaultClient
+
+// ReadOnlyMode rejects the votes with 503 Service Unavailable, e.g. while the database is backed up.
+var ReadOnlyMode = false
+
+// voteLimiter caps the votes accepted from all the voters at VoteRate per second.
+var voteLimiter = NewRateLimiter(VoteRate, VoteBurst)
+
 // badRequest is handled by setting the status code in the reply to StatusBadRequest.
 type badRequest struct{ error }
 
 // notFound is handled by setting the status code in the reply to StatusNotFound.
 type notFound struct{ error }
 
+// unauthorized is handled by setting the status code in the reply to StatusUnauthorized.
+type unauthorized struct{ error }
+
 // errorHandler wraps a function returning an error by handling the error and returning a http.Handler.
 // If the error is of the one of the types defined above, it is handled as described for every type.
 // If the error is of another type, it is considered as an internal error and its message is logged.
@@ -39,7 +68,9 @@ func errorHandler(f func(w http.ResponseWriter, r *http.Request) error) http.Han
 		case badRequest:
 			http.Error(w, err.Error(), http.StatusBadRequest)
 		case notFound:
-			http.Error(w, "task not found", http.StatusNotFound)
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case unauthorized:
+			http.Error(w, err.Error(), http.StatusUnauthorized)
 		default:
 			log.Println(err)
 			http.Error(w, "oops", http.StatusInternalServerError)
@@ -47,6 +78,48 @@ func errorHandler(f func(w http.ResponseWriter, r *http.Request) error) http.Han
 	}
 }
 
+// openImageManager returns an ImageManager connected to the database and loaded with all the stored puppies.
+// The caller is responsible for closing the database.
+func openImageManager() (*ImageManager, error) {
+	imageManager := NewImageManager()
+	if err := imageManager.InitDB(false); err != nil {
+		return nil, err
+	}
+
+	if err := imageManager.LoadImages(); err != nil {
+		imageManager.GetDB().Close()
+		return nil, err
+	}
+
+	return imageManager, nil
+}
+
+// pageLinks returns the links around page out of pages for a listing served under prefix,
+// keeping the query of the request.
+func pageLinks(r *http.Request, prefix string, page, pages int) *Links {
+	if page < 1 {
+		page = 1
+	}
+	if pages < 1 {
+		pages = 1
+	}
+
+	link := func(p int) string {
+		u := url.URL{Path: prefix + "/" + strconv.Itoa(p), RawQuery: r.URL.RawQuery}
+		return u.String()
+	}
+
+	links := &Links{Self: link(page), Last: link(pages)}
+	if page < pages {
+		links.Next = link(page + 1)
+	}
+	if page > 1 {
+		links.Prev = link(page - 1)
+	}
+
+	return links
+}
+
 func ListTopPuppies(w http.ResponseWriter, r *http.Request) {
 	page := mux.Vars(r)["page"]
 	if page == "" {
@@ -71,20 +144,49 @@ func ListTopPuppies(w http.ResponseWriter, r *http.Request) {
 	count := imageManager.GetPuppiesCount()
 
 	perPage := 10
-	pages := count / perPage
+	pages := (count + perPage - 1) / perPage
 
-	searchResponse := PuppiesResponse{pageInt, pages, perPage, count, puppies}
+	if err := imageManager.RecordImpressions(imageIDs(puppies)); err != nil {
+		log.Println(err)
+	}
+
+	searchResponse := PuppiesResponse{Page: pageInt, Pages: pages, PerPage: perPage, Total: count, Images: puppies}
+	if replyEmpty(w, &searchResponse) {
+		return
+	}
+	searchResponse.Links = pageLinks(r, TopPupsPrefix, pageInt, pages)
+	searchResponse.Token = IssueVoteToken(imageIDs(searchResponse.Images), time.Now())
+	translateTitles(r, searchResponse.Images)
 
-	response, err := json.Marshal(searchResponse)
+	response, err := marshalResponse(&searchResponse, r.URL.Query().Get("fields"))
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 	w.Header().Set("Content-Type", "application/json")
+	ResponseCachePolicy.Apply(w)
 	w.Write(response)
 
 }
 
+// imageIDs returns the ids of the images.
+func imageIDs(images []*Image) []string {
+	var ids []string
+	for _, im := range images {
+		ids = append(ids, im.ID)
+	}
+	return ids
+}
+
+// voterAddr identifies an anonymous voter by the host the request came from.
+func voterAddr(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
 func UpdatePuppy(w http.ResponseWriter, r *http.Request) {
 	var v Vote
 	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
@@ -93,17 +195,45 @@ func UpdatePuppy(w http.ResponseWriter, r *http.Request) {
 		println("some error")
 	}
 
-	imageManager := NewImageManager()
+	if err := VerifyVoteToken(v.Token, v.ID, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
+	}
 
-	dbError := imageManager.InitDB(false)
-	if dbError != nil {
-		log.Printf("%q\n", dbError)
+	imageManager, err := openImageManager()
+	if err != nil {
+		log.Printf("%q\n", err)
 		return
 	}
 
 	defer imageManager.GetDB().Close()
+	if v.Voter == "" {
+		v.Voter = voterAddr(r)
+	}
+	imageManager.VoteLimiter = voteLimiter
+	imageManager.SetReadOnly(ReadOnlyMode)
 	id, err := strconv.Atoi(v.ID)
-	imageManager.UpdateVotes(id, v.VT)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, _, err := imageManager.PersistVote(v.ID, v.VT); err == ErrRateLimited {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	} else if err == ErrReadOnly {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	} else if errors.Is(err, ErrImageNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if err != nil {
+		log.Println(err)
+		http.Error(w, "oops", http.StatusInternalServerError)
+		return
+	}
+	if err := imageManager.logVote(id, v.VT, v.Voter); err != nil {
+		log.Println(err)
+	}
 
 	response, err := json.Marshal(v)
 
@@ -115,69 +245,91 @@ func UpdatePuppy(w http.ResponseWriter, r *http.Request) {
 	w.Write(response)
 }
 
-func ListPuppies(w http.ResponseWriter, r *http.Request) {
-	page := mux.Vars(r)["page"]
-	if page == "" {
-		page = "1"
+// UndoPuppy takes back a vote, replying 204 No Content when the puppy had no such vote to take back.
+func UndoPuppy(w http.ResponseWriter, r *http.Request) {
+	var v Vote
+	if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
-	//tags := mux.Vars(r)["tags"]
-	tags := "puppies,dogs,cute"
 
-	baseUrl, err := url.Parse(FlickrEndPoint)
-	if err != nil {
-		log.Fatal(err)
+	if err := VerifyVoteToken(v.Token, v.ID, time.Now()); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return
 	}
 
-	params := url.Values{}
-	params.Add("method", FlickrQuery)
-	params.Add("api_key", FlickrKey)
-	params.Add("tags", tags)
-	params.Add("per_page", "10")
-	params.Add("page", page)
-	params.Add("safe_search", "2")
-	params.Add("sort", "date-posted-desc")
+	id, err := strconv.Atoi(v.ID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-	baseUrl.RawQuery = params.Encode()
+	imageManager := NewImageManager()
+	if err := imageManager.InitDB(false); err != nil {
+		log.Printf("%q\n", err)
+		return
+	}
+	defer imageManager.GetDB().Close()
 
-	resp, err := http.Get(baseUrl.String())
-	if err != nil {
-		// handle error, send proper error response
+	if v.Voter == "" {
+		v.Voter = voterAddr(r)
+	}
+	imageManager.SetReadOnly(ReadOnlyMode)
+	changed, err := imageManager.UndoVote(id, v.VT, v.Voter)
+	if err == ErrReadOnly {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	} else if err != nil {
 		log.Println(err)
+		http.Error(w, "oops", http.StatusInternalServerError)
+		return
 	}
-	defer resp.Body.Close()
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		// handle error, send proper error response
-		log.Println(err)
+	if !changed {
+		w.WriteHeader(http.StatusNoContent)
+		return
 	}
 
-	flickrResponse := struct {
-		Stat   string         `xml:"stat,attr"`
-		Err    flickrError    `xml:"err"`
-		Photos SearchResponse `xml:"photos"`
-	}{}
+	response, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	xml.Unmarshal([]byte(body), &flickrResponse)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(response)
+}
 
-	//stat := flickrResult.Stat
-	//if stat is "ok"
-	if flickrResponse.Stat != "ok" {
-		println(flickrResponse.Err.Msg)
-		//return error message
+func ListPuppies(w http.ResponseWriter, r *http.Request) {
+	page := mux.Vars(r)["page"]
+	if page == "" {
+		page = "1"
+	}
+	pageInt, err := strconv.Atoi(page)
+	if err != nil {
+		pageInt = 1
 	}
 
-	searchResponse := flickrResponse.Photos
-	flickrPhotos := searchResponse.Photos
+	//tags := mux.Vars(r)["tags"]
+	tags := "puppies,dogs,cute"
 
-	var tempIDs []string
-	imageManager := NewImageManager()
-	for _, ph := range flickrPhotos {
-		img := imageManager.NewImage(ph)
-		imageManager.Save(img)
-		tempIDs = append(tempIDs, img.ID)
+	searchResponse, err := SearchPhotos(SearchOptions{
+		Tags:     tags,
+		Page:     pageInt,
+		PerPage:  10,
+		CacheDir: FlickrCacheDir,
+		CacheTTL: FlickrCacheTTL,
+		Client:   FlickrClient,
+	})
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
 	}
 
+	imageManager := NewImageManager()
+	tempIDs := imageManager.ImportPhotos(searchResponse.Photos)
+
 	dbError := imageManager.InitDB(false)
 	if dbError != nil {
 		log.Printf("%q\n", dbError)
@@ -198,10 +350,7 @@ func ListPuppies(w http.ResponseWriter, r *http.Request) {
 			id := puppy.ID
 			for _, allP := range all {
 				//allPID, _ := strconv.Atoi(allP.ID)
-				if allP.ID == id {
-					allP.DownVotes = puppy.DownVotes
-					allP.UpVotes = puppy.UpVotes
-				} else {
+				if allP.ID != id {
 					exists := true
 					var existingPuppy *Image
 					for _, np := range newPuppies {
@@ -222,14 +371,34 @@ func ListPuppies(w http.ResponseWriter, r *http.Request) {
 		imageManager.InsertPuppies(newPuppies)
 	}
 
-	puppiesResponse := imageManager.GetPuppiesResponse(&searchResponse)
-	response, err := json.Marshal(puppiesResponse)
+	if _, err := imageManager.RestoreVotes(); err != nil {
+		log.Println(err)
+	}
+
+	if err := imageManager.RecordImpressions(tempIDs); err != nil {
+		log.Println(err)
+	}
+
+	puppiesResponse, err := imageManager.GetPuppiesResponse(searchResponse)
+	if err != nil {
+		log.Println(err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if replyEmpty(w, puppiesResponse) {
+		return
+	}
+	puppiesResponse.Links = pageLinks(r, PathPrefix, puppiesResponse.Page, puppiesResponse.Pages)
+	puppiesResponse.Token = IssueVoteToken(imageIDs(puppiesResponse.Images), time.Now())
+	translateTitles(r, puppiesResponse.Images)
+	response, err := marshalResponse(puppiesResponse, r.URL.Query().Get("fields"))
 
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	ResponseCachePolicy.Apply(w)
 	w.Write(response)
 }
 
@@ -263,6 +432,30 @@ func main() {
 	pupsUpdate := r.Path(PathPrefix).Subrouter()
 	pupsUpdate.Methods("PUT").HandlerFunc(UpdatePuppy)
 
+	pupsUndo := r.Path(UndoPath).Subrouter()
+	pupsUndo.Methods("POST").HandlerFunc(UndoPuppy)
+
+	metrics := r.Path(MetricsPath).Subrouter()
+	metrics.Methods("GET").Handler(errorHandler(MetricsHandler))
+
+	thumbnails := r.Path(ThumbnailPath + "/{id}").Subrouter()
+	thumbnails.Methods("GET").Handler(ThumbnailProxyHandler(http.DefaultClient))
+
+	sitemap := r.Path(SitemapPath).Subrouter()
+	sitemap.Methods("GET").Handler(errorHandler(SitemapHandler))
+
+	grid := r.Path(GridPath).Subrouter()
+	grid.Methods("GET").Handler(errorHandler(GridHandler))
+
+	refresh := r.Path(RefreshPath).Subrouter()
+	refresh.Methods("POST").Handler(errorHandler(RefreshHandler))
+
+	leaderboard := r.Path(LeaderboardPath).Subrouter()
+	leaderboard.Methods("GET").Handler(errorHandler(LeaderboardHTMLHandler))
+
+	index := r.Path(IndexPath).Subrouter()
+	index.Methods("GET").Handler(errorHandler(IndexHandler))
+
 	r.PathPrefix("/").Handler(http.FileServer(http.Dir("./static/")))
 	http.Handle("/", r)
 
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// putVote sends the vote to UpdatePuppy and returns the recorded response.
+func putVote(t *testing.T, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	w := httptest.NewRecorder()
+	UpdatePuppy(w, httptest.NewRequest("PUT", PathPrefix, strings.NewReader(body)))
+	return w
+}
+
+func TestUpdatePuppyPersistsVote(t *testing.T) {
+	m := newTestManager(t)
+	m.InsertPuppies([]*Image{{ID: "1", Title: "Rex"}})
+
+	token := IssueVoteToken([]string{"1"}, time.Now())
+	if w := putVote(t, `{"id": "1", "vt": true, "token": "`+token+`"}`); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+
+	stored := m.FindOldPuppies([]string{"1"})
+	if len(stored) != 1 || stored[0].UpVotes != 1 {
+		t.Errorf("stored = %v, want 1 up vote", stored)
+	}
+	if up, _, err := m.VoterActivity("192.0.2.1"); err != nil || up != 1 {
+		t.Errorf("VoterActivity = %d, %v, want the vote logged", up, err)
+	}
+}
+
+func TestUpdatePuppyUnknownImage(t *testing.T) {
+	newTestManager(t)
+
+	token := IssueVoteToken([]string{"1"}, time.Now())
+	if w := putVote(t, `{"id": "1", "vt": true, "token": "`+token+`"}`); w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+}
+
+func TestPageLinks(t *testing.T) {
+	r := httptest.NewRequest("GET", "/top/2?fields=id", nil)
+
+	links := pageLinks(r, TopPupsPrefix, 2, 3)
+	want := Links{Self: "/top/2?fields=id", Next: "/top/3?fields=id", Prev: "/top/1?fields=id", Last: "/top/3?fields=id"}
+	if *links != want {
+		t.Errorf("middle page links = %+v, want %+v", *links, want)
+	}
+
+	links = pageLinks(r, TopPupsPrefix, 3, 3)
+	want = Links{Self: "/top/3?fields=id", Prev: "/top/2?fields=id", Last: "/top/3?fields=id"}
+	if *links != want {
+		t.Errorf("last page links = %+v, want %+v", *links, want)
+	}
+}
+
+func TestListTopPuppiesLinks(t *testing.T) {
+	m := newTestManager(t)
+	var images []*Image
+	for i := 1; i <= 15; i++ {
+		images = append(images, &Image{ID: strconv.Itoa(i)})
+	}
+	m.InsertPuppies(images)
+
+	w := httptest.NewRecorder()
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/top/1", nil), map[string]string{"page": "1"})
+	ListTopPuppies(w, r)
+
+	var response PuppiesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("%v: %s", err, w.Body)
+	}
+	if response.Pages != 2 || response.Links.Next != "/top/2" || response.Links.Last != "/top/2" {
+		t.Errorf("pages = %d, links = %+v, want 2 pages with a next page", response.Pages, response.Links)
+	}
+}
+
+func TestListTopPuppiesHidesExpired(t *testing.T) {
+	m := newTestManager(t)
+	m.InsertPuppies([]*Image{{ID: "1", UpVotes: 1}, {ID: "2", UpVotes: 5}, {ID: "3", UpVotes: 3}})
+	if err := m.SetExpiry("2", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetExpiry("3", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	ListTopPuppies(w, mux.SetURLVars(httptest.NewRequest("GET", "/top/0", nil), map[string]string{"page": "0"}))
+
+	var response PuppiesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("%v: %s", err, w.Body)
+	}
+	if ids := imageIDs(response.Images); strings.Join(ids, ",") != "3,1" || response.Total != 2 {
+		t.Errorf("images = %v, total = %d, want [3 1] without the expired puppy", ids, response.Total)
+	}
+}
+
+func TestUndoPuppy(t *testing.T) {
+	m := newTestManager(t)
+	m.InsertPuppies([]*Image{{ID: "1", UpVotes: 1}})
+	token := IssueVoteToken([]string{"1"}, time.Now())
+	undo := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		UndoPuppy(w, httptest.NewRequest("POST", UndoPath, strings.NewReader(`{"id": "1", "vt": true, "token": "`+token+`"}`)))
+		return w
+	}
+
+	if w := undo(); w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 for a real undo: %s", w.Code, w.Body)
+	}
+	if stored := m.FindOldPuppies([]string{"1"}); len(stored) != 1 || stored[0].UpVotes != 0 {
+		t.Errorf("stored = %v, want the up vote taken back", stored)
+	}
+
+	if w := undo(); w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+		t.Errorf("status = %d, body = %q, want 204 without a vote left to undo", w.Code, w.Body)
+	}
+}
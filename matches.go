@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RecordMatch records in the matches table that the image with id winner was preferred to the one with id loser
+// in a head-to-head comparison. It returns ErrImageNotFound when either image is unknown.
+func (m *ImageManager) RecordMatch(winner, loser string) error {
+	if m.ReadOnly() {
+		return ErrReadOnly
+	}
+
+	for _, id := range []string{winner, loser} {
+		if _, ok := m.Find(id); !ok {
+			return fmt.Errorf("%w: %s", ErrImageNotFound, id)
+		}
+	}
+
+	_, err := m.exec("insert into matches(winner_id, loser_id, created_at) values(?, ?, ?)",
+		winner, loser, m.now().Unix())
+	return err
+}
+
+// WinMatrix returns, for every pair of the given ids, how many matches the first one won against the second,
+// so that matrix[a][b] is the number of wins of a over b. Pairs without any win are left out.
+func (m *ImageManager) WinMatrix(ids []string) (map[string]map[string]int, error) {
+	matrix := make(map[string]map[string]int)
+	if len(ids) == 0 {
+		return matrix, nil
+	}
+
+	in := strings.Join(strings.Split(strings.Repeat("?", len(ids)), ""), ",")
+	args := make([]interface{}, 0, 2*len(ids))
+	for i := 0; i < 2; i++ {
+		for _, id := range ids {
+			args = append(args, id)
+		}
+	}
+
+	rows, err := m.query(fmt.Sprintf(`select winner_id, loser_id, count(*) from matches
+		where winner_id in (%s) and loser_id in (%s) group by winner_id, loser_id`, in, in), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var winner, loser string
+		var wins int
+		if err := rows.Scan(&winner, &loser, &wins); err != nil {
+			return nil, err
+		}
+		if matrix[winner] == nil {
+			matrix[winner] = make(map[string]int)
+		}
+		matrix[winner][loser] = wins
+	}
+
+	return matrix, rows.Err()
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestWinMatrix(t *testing.T) {
+	m := newTestManager(t)
+	saveImages(t, m, &Image{ID: "1"}, &Image{ID: "2"}, &Image{ID: "3"})
+
+	for _, match := range [][2]string{{"1", "2"}, {"1", "2"}, {"2", "1"}, {"3", "1"}, {"2", "3"}} {
+		if err := m.RecordMatch(match[0], match[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.RecordMatch("1", "4"); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("RecordMatch against an unknown image error = %v, want ErrImageNotFound", err)
+	}
+
+	matrix, err := m.WinMatrix([]string{"1", "2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]map[string]int{"1": {"2": 2}, "2": {"1": 1}}; !reflect.DeepEqual(matrix, want) {
+		t.Errorf("WinMatrix = %v, want %v", matrix, want)
+	}
+
+	matrix, err = m.WinMatrix([]string{"1", "2", "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]map[string]int{"1": {"2": 2}, "2": {"1": 1, "3": 1}, "3": {"1": 1}}; !reflect.DeepEqual(matrix, want) {
+		t.Errorf("WinMatrix = %v, want %v", matrix, want)
+	}
+}
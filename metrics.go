@@ -0,0 +1,66 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// metricTypes maps every metric reported by Metrics to its Prometheus metric type.
+var metricTypes = map[string]string{
+	"puppies_images":     "gauge",
+	"puppies_up_votes":   "gauge",
+	"puppies_down_votes": "gauge",
+}
+
+// Metrics returns the gauges describing the images in the ImageManager, keyed by metric name. The vote totals
+// are gauges rather than counters since an undone vote or a deleted image lowers them.
+func (m *ImageManager) Metrics() map[string]int {
+	images := m.All()
+
+	up, down := 0, 0
+	for _, im := range images {
+		up += im.UpVotes
+		down += im.DownVotes
+	}
+
+	return map[string]int{
+		"puppies_images":     len(images),
+		"puppies_up_votes":   up,
+		"puppies_down_votes": down,
+	}
+}
+
+// MetricsHandler writes the metrics of the stored puppies in the Prometheus text exposition format.
+func MetricsHandler(w http.ResponseWriter, r *http.Request) error {
+	imageManager, err := openImageManager()
+	if err != nil {
+		return err
+	}
+	defer imageManager.GetDB().Close()
+
+	metrics := imageManager.Metrics()
+
+	var names []string
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, name := range names {
+		fmt.Fprintf(w, "# TYPE %s %s\n", name, metricTypes[name])
+		fmt.Fprintf(w, "%s %d\n", name, metrics[name])
+	}
+
+	return nil
+}
+
+// DBStats returns the statistics of the database connection pool, or zero statistics when no database is open.
+func (m *ImageManager) DBStats() sql.DBStats {
+	if m.db == nil {
+		return sql.DBStats{}
+	}
+	return m.db.Stats()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	m := newTestManager(t)
+	m.InsertPuppies([]*Image{{ID: "1", UpVotes: 3, DownVotes: 1}, {ID: "2", UpVotes: 2}})
+
+	w := httptest.NewRecorder()
+	if err := MetricsHandler(w, httptest.NewRequest("GET", MetricsPath, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `# TYPE puppies_down_votes gauge
+puppies_down_votes 1
+# TYPE puppies_images gauge
+puppies_images 2
+# TYPE puppies_up_votes gauge
+puppies_up_votes 5
+`
+	if got := w.Body.String(); got != want {
+		t.Errorf("exposition =\n%s\nwant\n%s", got, want)
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+}
+
+func TestDBStats(t *testing.T) {
+	if stats := NewImageManager().DBStats(); stats.OpenConnections != 0 {
+		t.Errorf("stats without database = %+v, want zero", stats)
+	}
+
+	m := newTestManager(t)
+	m.InsertPuppies([]*Image{{ID: "1"}})
+
+	rows, err := m.query("select puppy_id from votes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := m.DBStats(); stats.InUse != 1 || stats.OpenConnections < 1 {
+		t.Errorf("stats while reading = %+v, want a connection in use", stats)
+	}
+	rows.Close()
+
+	if stats := m.DBStats(); stats.InUse != 0 || stats.Idle < 1 {
+		t.Errorf("stats after reading = %+v, want the connection back idle", stats)
+	}
+}
@@ -2,27 +2,55 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	_ "github.com/mattn/go-sqlite3"
+	"hash/fnv"
 	"log"
+	"math/rand"
+	"net/http"
 	"os"
+	"sort"
 	"strconv"
-	"strings"
+	"sync"
+	"time"
 )
 
+// ErrImageNotFound is returned for the ids matching no image in the ImageManager.
+var ErrImageNotFound = errors.New("image not found")
+
+// ErrReadOnly is returned by the methods writing to an ImageManager in read-only mode.
+var ErrReadOnly = errors.New("image manager is read-only")
+
 // Image sizes supported by Flickr.  See
-// http://www.flickr.com/services/api/misc.urls.html for more information.
+// https://www.flickr.com/services/api/misc.urls.html for more information.
 const (
 	SizeSmallSquare = "s"
 	SizeThumbnail   = "t"
 	SizeSmall       = "m"
 	SizeMedium500   = "-"
 	SizeMedium640   = "z"
+	SizeMedium800   = "c"
 	SizeLarge       = "b"
+	SizeLarge1600   = "h"
 	SizeOriginal    = "o"
 	DatabaseName    = "puppies.sqlite"
 )
 
+// photoSizes are the sizes Photo.URL knows about.
+var photoSizes = map[string]bool{
+	SizeSmallSquare: true,
+	SizeThumbnail:   true,
+	SizeSmall:       true,
+	SizeMedium500:   true,
+	SizeMedium640:   true,
+	SizeMedium800:   true,
+	SizeLarge:       true,
+	SizeLarge1600:   true,
+	SizeOriginal:    true,
+}
+
 // Response for photo search requests.
 type SearchResponse struct {
 	Page    string  `xml:"page,attr"`
@@ -45,6 +73,12 @@ type Photo struct {
 	IsFamily    string `xml:"isfamily,attr"`
 	Thumbnail_T string `xml:"thumbnail_t,attr"`
 	Large_T     string `xml:"large_t,attr"`
+	Latitude    string `xml:"latitude,attr"`
+	Longitude   string `xml:"longitude,attr"`
+
+	// OriginalWidth and OriginalHeight are the dimensions of the original photo, when requested with the o_dims extra.
+	OriginalWidth  string `xml:"o_width,attr"`
+	OriginalHeight string `xml:"o_height,attr"`
 }
 
 type flickrError struct {
@@ -59,6 +93,92 @@ type Image struct {
 	Large     string `json:"large"`
 	UpVotes   int    `json:"upvotes"`
 	DownVotes int    `json:"downvotes"`
+	Owner     string `json:"owner,omitempty"`
+
+	// Latitude and Longitude locate where the photo was taken. Both are 0 when it isn't geotagged.
+	Latitude  float64 `json:"latitude,omitempty"`
+	Longitude float64 `json:"longitude,omitempty"`
+
+	// Impressions counts how many times the image was served in a listing.
+	Impressions int `json:"impressions"`
+
+	// RatingSum and RatingCount accumulate the star ratings of the image when the StarScale is used.
+	RatingSum   int `json:"rating_sum,omitempty"`
+	RatingCount int `json:"rating_count,omitempty"`
+
+	// CropHint tells the UI which grid cell fits the photo: "square", "landscape" or "portrait".
+	// It is empty when the dimensions of the photo are unknown.
+	CropHint string `json:"crop_hint,omitempty"`
+
+	// ExpiresAt is when the image stops being visible. The zero time never expires.
+	ExpiresAt time.Time `json:"-"`
+
+	// AddedAt is when the image was saved in the ImageManager.
+	AddedAt time.Time `json:"-"`
+}
+
+// JSONNaming selects the style of the image field names in JSON: "lower" (upvotes, the default) or
+// "camel" (upVotes). It can be set at build time with -ldflags "-X main.JSONNaming=camel".
+var JSONNaming = "lower"
+
+// lowerImage is an Image marshalled with its own field tags.
+type lowerImage Image
+
+// camelImage is an Image marshalled with camelCase field names. It must keep the fields of Image.
+type camelImage struct {
+	ID        string `json:"id"`
+	Title     string `json:"title"`
+	Thumbnail string `json:"thumbnail"`
+	Large     string `json:"large"`
+	UpVotes   int    `json:"upVotes"`
+	DownVotes int    `json:"downVotes"`
+	Owner     string `json:"owner,omitempty"`
+
+	Latitude  float64 `json:"latitude,omitempty"`
+	Longitude float64 `json:"longitude,omitempty"`
+
+	Impressions int `json:"impressions"`
+
+	RatingSum   int `json:"ratingSum,omitempty"`
+	RatingCount int `json:"ratingCount,omitempty"`
+
+	CropHint string `json:"cropHint,omitempty"`
+
+	ExpiresAt time.Time `json:"-"`
+	AddedAt   time.Time `json:"-"`
+}
+
+// IncludeAttribution adds the attribution of every image to its JSON, as required by the Flickr license.
+var IncludeAttribution = false
+
+// MarshalJSON encodes the image with the field naming selected by JSONNaming, along with its Approval
+// and, when IncludeAttribution is set, its attribution.
+func (i Image) MarshalJSON() ([]byte, error) {
+	var attribution string
+	if IncludeAttribution {
+		attribution = i.Attribution()
+	}
+
+	if JSONNaming == "camel" {
+		return json.Marshal(struct {
+			camelImage
+			Approval    RoundedScore `json:"approval"`
+			Attribution string       `json:"attribution,omitempty"`
+		}{camelImage(i), RoundedScore(i.Approval()), attribution})
+	}
+	return json.Marshal(struct {
+		lowerImage
+		Approval    RoundedScore `json:"approval"`
+		Attribution string       `json:"attribution,omitempty"`
+	}{lowerImage(i), RoundedScore(i.Approval()), attribution})
+}
+
+// Attribution credits the owner of the photo, or is empty when the owner is unknown.
+func (i *Image) Attribution() string {
+	if i.Owner == "" {
+		return ""
+	}
+	return "Photo by " + i.Owner
 }
 
 type PuppiesResponse struct {
@@ -67,113 +187,572 @@ type PuppiesResponse struct {
 	PerPage int      `json:"perpage"`
 	Total   int      `json:"total"`
 	Images  []*Image `json:"images"`
+	Links   *Links   `json:"links,omitempty"`
+
+	// Token must be sent back along with the votes on the listed images.
+	Token string `json:"token,omitempty"`
+}
+
+// Links holds the URLs of the pages around the current page of a paginated response.
+// Next and Prev are empty on the last and the first page.
+type Links struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+	Last string `json:"last"`
 }
 
 type ImageManager struct {
-	images []*Image
-	db     *sql.DB
+	mu       sync.RWMutex
+	images   []*Image
+	db       *sql.DB
+	readOnly bool
+
+	// featured holds, per image id, the time until which the image is featured.
+	featured map[string]time.Time
+
+	// featuredCount holds, per image id, how many times the image was featured.
+	featuredCount map[string]int
+
+	// rand is the source of the randomized methods, guarded by randMu since it isn't safe for concurrent use.
+	randMu sync.Mutex
+	rand   *rand.Rand
+
+	// sizes caches the size URLs returned by FetchSizes, keyed by photo id then size label.
+	sizesMu sync.Mutex
+	sizes   map[string]map[string]string
+
+	// voteLocks serialize the votes on the images hashing to the same shard,
+	// so that votes on different images don't wait for each other. Every change to the vote counts
+	// takes the vote lock of its image, or all of them when it touches many images.
+	voteLocks [voteLockShards]sync.Mutex
+
+	// Votes persists the puppies and their votes. It defaults to a SQLiteVoteStore on the database opened by InitDB.
+	Votes VoteStore
+
+	// VoteLimiter caps the rate of the votes accepted by UpdateVotes and PersistVote across all voters. Nil means no limit.
+	VoteLimiter *RateLimiter
+
+	// VoteScale selects how the images are voted on. It defaults to UpDownScale.
+	VoteScale VoteScale
+
+	// IDGenerator gives an id to the saved images which have none, e.g. because they don't come from Flickr.
+	// It must always return the same id for the same image. It defaults to URLHashID.
+	IDGenerator func(*Image) string
+
+	// PhotoFilter, when set, is called on every imported photo and drops the ones it returns false for.
+	PhotoFilter func(Photo) bool
+
+	// FairInterleave is how many top-scored images FairOrder places before each least-shown image.
+	// It defaults to DefaultFairInterleave.
+	FairInterleave int
+
+	// WebhookURL, when set, receives a POST whenever an image reaches one of the VoteMilestones up votes.
+	WebhookURL     string
+	VoteMilestones []int
+
+	// HTTPClient sends the requests of the ImageManager. It defaults to http.DefaultClient.
+	HTTPClient *http.Client
+
+	// NoClone makes Save store the passed image pointer instead of a copy of it, filling in its ID and AddedAt
+	// in place when they are missing. It speeds up bulk imports, but the caller must not modify an image after
+	// saving it, since any change is then visible to the ImageManager as well.
+	NoClone bool
+
+	// Clock returns the current time. It defaults to time.Now.
+	Clock func() time.Time
+
+	// BusyTimeout is how long a statement waits for the database to be unlocked by another writer before
+	// failing, set by InitDB as the busy_timeout of every connection. It defaults to DefaultBusyTimeout.
+	BusyTimeout time.Duration
+
+	// QualityWeights weigh the signals blended by QualityScore. They default to DefaultQualityWeights.
+	QualityWeights QualityWeights
+
+	// SlowQueryThreshold, when set, makes the database queries taking longer than it logged as warnings.
+	SlowQueryThreshold time.Duration
+
+	// Logger receives the warnings about slow queries. It defaults to the standard logger.
+	Logger *log.Logger
+
+	// TieBreaker reports whether a ranks before b in the rankings when both have the same net score.
+	// It defaults to favouring the image with more up votes.
+	TieBreaker func(a, b *Image) bool
+}
+
+// DefaultBusyTimeout is the default BusyTimeout of an ImageManager.
+const DefaultBusyTimeout = 5 * time.Second
+
+// voteLockShards is the number of locks the votes on the images are striped over.
+const voteLockShards = 32
+
+// voteLock returns the lock serializing the votes on the image with the given id.
+func (m *ImageManager) voteLock(id string) *sync.Mutex {
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return &m.voteLocks[h.Sum32()%voteLockShards]
+}
+
+// lockAllVotes takes every vote lock, in order, for the operations changing the votes of many images at once.
+// Like a single vote lock, it must be taken before m.mu.
+func (m *ImageManager) lockAllVotes() {
+	for i := range m.voteLocks {
+		m.voteLocks[i].Lock()
+	}
+}
+
+// unlockAllVotes releases the vote locks taken by lockAllVotes.
+func (m *ImageManager) unlockAllVotes() {
+	for i := range m.voteLocks {
+		m.voteLocks[i].Unlock()
+	}
 }
 
 type Vote struct {
-	ID string `json:"id"`
-	VT bool   `json:"vt"`
+	ID    string `json:"id"`
+	VT    bool   `json:"vt"`
+	Voter string `json:"voter,omitempty"`
+	Token string `json:"token,omitempty"`
 }
 
 func NewImageManager() *ImageManager {
 	return &ImageManager{}
 }
 
-func (m *ImageManager) GetPuppiesResponse(searchResponse *SearchResponse) *PuppiesResponse {
-	page, err := strconv.Atoi(searchResponse.Page)
-	pages, err := strconv.Atoi(searchResponse.Pages)
-	perPage, err := strconv.Atoi(searchResponse.PerPage)
-	total, err := strconv.Atoi(searchResponse.Total)
+// GetPuppiesResponse returns the visible images paginated as described by the search response.
+// Empty numeric attributes, as sent by Flickr for searches without results, are read as 0.
+func (m *ImageManager) GetPuppiesResponse(searchResponse *SearchResponse) (*PuppiesResponse, error) {
+	page, err := atoiOrZero(searchResponse.Page)
 	if err != nil {
-		log.Println(err)
-		return nil
+		return nil, fmt.Errorf("parsing page %q: %w", searchResponse.Page, err)
+	}
+	pages, err := atoiOrZero(searchResponse.Pages)
+	if err != nil {
+		return nil, fmt.Errorf("parsing pages %q: %w", searchResponse.Pages, err)
+	}
+	perPage, err := atoiOrZero(searchResponse.PerPage)
+	if err != nil {
+		return nil, fmt.Errorf("parsing perpage %q: %w", searchResponse.PerPage, err)
+	}
+	total, err := atoiOrZero(searchResponse.Total)
+	if err != nil {
+		return nil, fmt.Errorf("parsing total %q: %w", searchResponse.Total, err)
+	}
+	return &PuppiesResponse{Page: page, Pages: pages, PerPage: perPage, Total: total, Images: m.Visible()}, nil
+}
+
+// atoiOrZero is strconv.Atoi reading the empty string as 0.
+func atoiOrZero(s string) (int, error) {
+	if s == "" {
+		return 0, nil
 	}
-	return &PuppiesResponse{page, pages, perPage, total, m.images}
+	return strconv.Atoi(s)
+}
+
+// SnapshotResponse returns the given page of the visible images, perPage images per page, copied under a single
+// read lock so that votes cast meanwhile can't show up halfway through the page.
+func (m *ImageManager) SnapshotResponse(page, perPage int) *PuppiesResponse {
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 {
+		perPage = 10
+	}
+
+	now := m.now()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var visible []*Image
+	for _, im := range m.images {
+		if im.visibleAt(now) {
+			visible = append(visible, im)
+		}
+	}
+
+	total := len(visible)
+	start := (page - 1) * perPage
+	if start > total {
+		start = total
+	}
+	end := start + perPage
+	if end > total {
+		end = total
+	}
+
+	images := make([]*Image, 0, end-start)
+	for _, im := range visible[start:end] {
+		images = append(images, cloneImage(im))
+	}
+
+	pages := (total + perPage - 1) / perPage
+	return &PuppiesResponse{Page: page, Pages: pages, PerPage: perPage, Total: total, Images: images}
 }
 
 func (m *ImageManager) NewImage(photo Photo) *Image {
-	return &Image{photo.ID, photo.Title, photo.URL(SizeThumbnail), photo.URL(SizeLarge), 0, 0}
+	latitude, _ := strconv.ParseFloat(photo.Latitude, 64)
+	longitude, _ := strconv.ParseFloat(photo.Longitude, 64)
+	width, _ := strconv.Atoi(photo.OriginalWidth)
+	height, _ := strconv.Atoi(photo.OriginalHeight)
+	return &Image{ID: photo.ID, Title: photo.Title, Thumbnail: photo.URL(SizeThumbnail), Large: photo.URL(SizeLarge),
+		Owner: photo.Owner, Latitude: latitude, Longitude: longitude, CropHint: cropHint(width, height)}
 }
 
+// ImportPhotos saves an image for each of the photos accepted by the PhotoFilter and returns their ids.
+func (m *ImageManager) ImportPhotos(photos []Photo) []string {
+	var ids []string
+	for _, ph := range photos {
+		if m.PhotoFilter != nil && !m.PhotoFilter(ph) {
+			continue
+		}
+
+		img := m.NewImage(ph)
+		m.Save(img)
+		ids = append(ids, m.idFor(img))
+	}
+
+	return ids
+}
+
+// SetReadOnly switches the read-only mode, during which writes return ErrReadOnly while reads go on.
+func (m *ImageManager) SetReadOnly(readOnly bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.readOnly = readOnly
+}
+
+// ReadOnly reports whether the ImageManager is in read-only mode.
+func (m *ImageManager) ReadOnly() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.readOnly
+}
+
+// idFor returns the id of the image, or the one the IDGenerator gives it when it has none.
+func (m *ImageManager) idFor(image *Image) string {
+	if image.ID != "" {
+		return image.ID
+	}
+	if m.IDGenerator != nil {
+		return m.IDGenerator(image)
+	}
+	return URLHashID(image)
+}
+
+// Save adds the image to the ImageManager unless it already has an image with the same id.
+// An image without id is given one by the IDGenerator, and an image without AddedAt the current time.
+// Both are set on the stored copy, so that the passed image is left alone unless NoClone stores it as is.
 func (m *ImageManager) Save(image *Image) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.readOnly {
+		return ErrReadOnly
+	}
+
+	id := m.idFor(image)
 	for _, im := range m.images {
-		if im.ID == image.ID {
+		if im.ID == id {
 			return nil
 		}
 	}
 
-	m.images = append(m.images, cloneImage(image))
+	stored := image
+	if !m.NoClone {
+		stored = cloneImage(image)
+	}
+	stored.ID = id
+	if stored.AddedAt.IsZero() {
+		stored.AddedAt = m.now()
+	}
+	m.images = append(m.images, stored)
 	return nil
 }
 
+// Find returns a copy of the image with the given id, taken under the read lock.
 func (m *ImageManager) Find(ID string) (*Image, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	for _, im := range m.images {
 		if im.ID == ID {
-			return im, true
+			return cloneImage(im), true
 		}
 	}
 
 	return nil, false
 }
 
-func (m *ImageManager) Update(image *Image, upOrDown bool) (int, int) {
+// modify calls f on the image with the given id under the write lock, reporting whether the image was found.
+func (m *ImageManager) modify(id string, f func(*Image)) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, im := range m.images {
+		if im.ID == id {
+			f(im)
+			return true
+		}
+	}
+
+	return false
+}
+
+// setVotes sets the vote counts of the image with the given id, reporting whether it was found.
+func (m *ImageManager) setVotes(id string, upVotes, downVotes int) bool {
+	return m.modify(id, func(im *Image) {
+		im.UpVotes = upVotes
+		im.DownVotes = downVotes
+	})
+}
+
+// Merge copies into the ImageManager the images of other it doesn't have yet and adds up the counters
+// (votes, impressions and ratings) of the images both have. It returns how many images were copied.
+func (m *ImageManager) Merge(other *ImageManager) (added int) {
+	if other == m || m.ReadOnly() {
+		return 0
+	}
+
+	// Take a copy first so that the two managers are never locked at the same time.
+	other.mu.RLock()
+	images := make([]*Image, len(other.images))
+	for i, im := range other.images {
+		images[i] = cloneImage(im)
+	}
+	other.mu.RUnlock()
+
+	m.lockAllVotes()
+	defer m.unlockAllVotes()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, image := range images {
+		found := false
+		for _, im := range m.images {
+			if im.ID == image.ID {
+				im.addCounters(image)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			m.images = append(m.images, image)
+			added++
+		}
+	}
+
+	return added
+}
+
+// DeleteMany removes the images with the given ids from the ImageManager and from its VoteStore.
+// It returns how many images were removed; unknown ids are ignored.
+func (m *ImageManager) DeleteMany(ids []string) (int, error) {
+	if m.ReadOnly() {
+		return 0, ErrReadOnly
+	}
+
+	deleted := make(map[string]bool)
+
+	if m.persistent() {
+		stored, err := m.voteStore().Delete(ids)
+		if err != nil {
+			return 0, err
+		}
+		for _, id := range stored {
+			deleted[id] = true
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, id := range ids {
+		for i, im := range m.images {
+			if im.ID == id {
+				m.images = append(m.images[:i], m.images[i+1:]...)
+				deleted[id] = true
+				break
+			}
+		}
+	}
+
+	return len(deleted), nil
+}
+
+// Update adds an up or a down vote to the image with the ID of image, writing the vote through to
+// the VoteStore when the ImageManager has one or a database. The counts are then those of the VoteStore,
+// which include the votes cast through other ImageManagers, and otherwise those of the stored image,
+// whatever the ones of image are. It returns the new up and down votes of the image.
+//
+// The votes on an image are serialized by its vote lock, so that votes on different images are persisted
+// concurrently. m.mu is only held briefly to read and then write the counts in memory, never while persisting,
+// since All and the other readers access the counts of the images under it.
+func (m *ImageManager) Update(image *Image, upOrDown bool) (int, int, error) {
+	if m.ReadOnly() {
+		return 0, 0, ErrReadOnly
+	}
+
+	lock := m.voteLock(image.ID)
+	lock.Lock()
+	defer lock.Unlock()
+
+	stored, ok := m.Find(image.ID)
+	if !ok {
+		return 0, 0, ErrImageNotFound
+	}
+	upVotes, downVotes := stored.UpVotes, stored.DownVotes
+
 	if upOrDown == true {
-		image.UpVotes++
+		upVotes++
 	} else {
-		image.DownVotes--
+		downVotes++
 	}
 
-	for _, im := range m.images {
-		if im.ID == image.ID {
-			im.UpVotes = image.UpVotes
-			im.DownVotes = image.DownVotes
+	if m.persistent() {
+		counts, found, err := m.voteStore().Increment(image.ID, upOrDown)
+		if err != nil {
+			return stored.UpVotes, stored.DownVotes, err
 		}
+		if !found {
+			return stored.UpVotes, stored.DownVotes, fmt.Errorf("persisting votes of %s: %w", image.ID, ErrImageNotFound)
+		}
+		upVotes, downVotes = counts.UpVotes, counts.DownVotes
 	}
 
-	return image.UpVotes, image.DownVotes
+	if !m.setVotes(image.ID, upVotes, downVotes) {
+		return 0, 0, fmt.Errorf("updating votes of %s: %w", image.ID, ErrImageNotFound)
+	}
+
+	if upOrDown {
+		m.upVoted(image.ID, upVotes)
+	}
+
+	return upVotes, downVotes, nil
 }
 
-func (m *ImageManager) UpdateVotes(puppy_id int, up_vote bool) {
-	sqlStmt := "update votes set "
-	if up_vote == true {
-		sqlStmt += " up_votes = up_votes + 1"
-	} else {
-		sqlStmt += " down_votes = down_votes + 1"
+// PersistVote finds the image with the given id and adds an up or a down vote to it,
+// in memory and in the VoteStore. It returns the new up and down votes of the image,
+// or ErrRateLimited when the VoteLimiter rejects the vote.
+func (m *ImageManager) PersistVote(id string, up bool) (int, int, error) {
+	if m.ReadOnly() {
+		return 0, 0, ErrReadOnly
 	}
 
-	sqlStmt += " where puppy_id = ?"
+	if m.VoteLimiter != nil && !m.VoteLimiter.Allow() {
+		return 0, 0, ErrRateLimited
+	}
+
+	image, ok := m.Find(id)
+	if !ok {
+		return 0, 0, ErrImageNotFound
+	}
+
+	return m.Update(image, up)
+}
 
-	stmt, err := m.db.Prepare(sqlStmt)
+// UpdateVotes adds an up or a down vote cast by voter to the stored puppy and records it in the vote_log.
+// It returns ErrRateLimited when the VoteLimiter rejects the vote.
+func (m *ImageManager) UpdateVotes(puppy_id int, up_vote bool, voter string) error {
+	if m.ReadOnly() {
+		return ErrReadOnly
+	}
+
+	if m.VoteLimiter != nil && !m.VoteLimiter.Allow() {
+		return ErrRateLimited
+	}
+
+	counts, found, err := m.voteStore().Increment(strconv.Itoa(puppy_id), up_vote)
 	if err != nil {
-		log.Fatal(err)
+		return err
+	}
+
+	if found && m.db != nil {
+		if err := m.logVote(puppy_id, up_vote, voter); err != nil {
+			log.Println(err)
+		}
+
+		if up_vote {
+			m.upVoted(counts.ID, counts.UpVotes)
+		}
 	}
 
-	defer stmt.Close()
+	return nil
+}
 
-	res, _ := stmt.Exec(puppy_id)
+// UndoVote removes an up or a down vote from the stored puppy, along with the latest such vote in the vote_log,
+// preferably one cast by voter. It reports whether a vote was removed, which isn't the case when the puppy
+// has no vote of that kind left.
+func (m *ImageManager) UndoVote(puppy_id int, up_vote bool, voter string) (changed bool, err error) {
+	if m.ReadOnly() {
+		return false, ErrReadOnly
+	}
 
-	affect, _ := res.RowsAffected()
+	changed, err = m.voteStore().Decrement(strconv.Itoa(puppy_id), up_vote)
+	if err != nil || !changed {
+		return false, err
+	}
 
-	fmt.Println(affect)
+	if m.db != nil {
+		if err := m.unlogVote(puppy_id, up_vote, voter); err != nil {
+			log.Println(err)
+		}
+	}
 
-	return
+	return true, nil
 }
 
-func (m *ImageManager) GetPuppiesCount() int {
-	query := "select count(id) from votes"
+// Counts returns the number of images in memory along with the number of puppies in the VoteStore,
+// so that any drift between the two shows at a glance.
+func (m *ImageManager) Counts() (memImages, dbVoteRows int, err error) {
+	m.mu.RLock()
+	memImages = len(m.images)
+	m.mu.RUnlock()
 
-	rows, err := m.db.Query(query)
+	dbVoteRows, err = m.voteStore().Count()
+	return memImages, dbVoteRows, err
+}
+
+// VoteSummary is the compact vote record of an image, for list views.
+type VoteSummary struct {
+	ID    string `json:"id"`
+	Up    int    `json:"up"`
+	Down  int    `json:"down"`
+	Score int    `json:"score"`
+}
+
+// VoteSummaries returns the stored votes and net score of the puppies with the given ids, in the order of ids.
+// Unknown ids are skipped.
+func (m *ImageManager) VoteSummaries(ids []string) ([]VoteSummary, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	stored, err := m.voteStore().Load(ids)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+
+	byID := make(map[string]*Image)
+	for _, im := range stored {
+		byID[im.ID] = im
 	}
-	defer rows.Close()
-	count := 0
-	for rows.Next() {
-		rows.Scan(&count)
+
+	var rs []VoteSummary
+	for _, id := range ids {
+		if im, ok := byID[id]; ok {
+			rs = append(rs, VoteSummary{ID: im.ID, Up: im.UpVotes, Down: im.DownVotes, Score: im.Score()})
+		}
+	}
+
+	return rs, nil
+}
+
+func (m *ImageManager) GetPuppiesCount() int {
+	count, err := m.voteStore().CountVisible(m.now())
+	if err != nil {
+		log.Fatal(err)
 	}
 
 	return count
@@ -185,41 +764,164 @@ func (m *ImageManager) GetPuppiesByMostVotes(pageId int) []*Image {
 		pageId-- 
 	}
 	start := perPage * pageId
-	query := "select * from votes order by up_votes desc limit ?,?"
 
-	stmt, err := m.db.Prepare(query)
+	rs, err := m.voteStore().Top(start, perPage, m.now())
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	defer stmt.Close()
-	rows, err := stmt.Query(start, perPage)
+	return rs
+
+}
+
+// LoadImages replaces the images in the ImageManager with all the puppies stored in its VoteStore.
+func (m *ImageManager) LoadImages() error {
+	rs, err := m.voteStore().LoadAll()
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
-	defer rows.Close()
+	m.mu.Lock()
+	m.images = rs
+	m.mu.Unlock()
+	return nil
+}
 
-	var rs []*Image
+// CatalogETag returns a hash over the ids and vote counts of all the images, suitable for an ETag header.
+// It does not depend on the order of the images and changes whenever a vote changes.
+func (m *ImageManager) CatalogETag() string {
+	images := m.All()
+	sort.Slice(images, func(i, j int) bool { return images[i].ID < images[j].ID })
 
-	for rows.Next() {
-		var dbImage Image
-		var id int
-		rows.Scan(&id, &dbImage.ID, &dbImage.Title, &dbImage.Thumbnail, &dbImage.Large, &dbImage.UpVotes, &dbImage.DownVotes)
-		rs = append(rs, &dbImage)
+	h := fnv.New64a()
+	for _, im := range images {
+		fmt.Fprintf(h, "%s:%d:%d;", im.ID, im.UpVotes, im.DownVotes)
 	}
 
-	if err = rows.Err(); err != nil {
-		log.Fatal(err)
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// SetExpiry makes the image invisible from t on. The zero time makes it visible again for good.
+// The expiry is stored in the VoteStore as well, so that the listings read from it leave the image out.
+func (m *ImageManager) SetExpiry(id string, t time.Time) error {
+	found := m.modify(id, func(im *Image) { im.ExpiresAt = t })
+
+	if m.persistent() {
+		stored, err := m.voteStore().SetExpiry(id, t)
+		if err != nil {
+			return err
+		}
+		found = found || stored
+	}
+
+	if !found {
+		return ErrImageNotFound
+	}
+	return nil
+}
+
+// visibleAt reports whether the image has not expired yet at now.
+func (im *Image) visibleAt(now time.Time) bool {
+	return im.ExpiresAt.IsZero() || now.Before(im.ExpiresAt)
+}
+
+// Visible returns the images which have not expired yet.
+func (m *ImageManager) Visible() []*Image {
+	now := m.now()
+
+	var rs []*Image
+	for _, im := range m.All() {
+		if im.visibleAt(now) {
+			rs = append(rs, im)
+		}
 	}
 
 	return rs
+}
+
+// StaleImages returns the images added before olderThan ago, whose Flickr metadata should be fetched again.
+// Images of unknown age, such as the ones loaded from the database, are always stale.
+func (m *ImageManager) StaleImages(olderThan time.Duration) []*Image {
+	cutoff := m.now().Add(-olderThan)
 
+	var rs []*Image
+	for _, im := range m.All() {
+		if im.AddedAt.Before(cutoff) {
+			rs = append(rs, im)
+		}
+	}
+
+	return rs
 }
 
-// All returns the list of all the Tasks in the TaskManager.
+// All returns a copy of all the images in the ImageManager, which can't change underneath the caller.
 func (m *ImageManager) All() []*Image {
-	return m.images
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	images := make([]*Image, len(m.images))
+	for i, im := range m.images {
+		images[i] = cloneImage(im)
+	}
+	return images
+}
+
+// Score returns the net score of the image, its up votes minus its down votes.
+func (i *Image) Score() int {
+	return i.UpVotes - i.DownVotes
+}
+
+// ContentKey returns a hash over the title and the large URL of the image, identifying the same photo
+// imported under different ids.
+func (i *Image) ContentKey() string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\n%s", i.Title, i.Large)
+	return strconv.FormatUint(h.Sum64(), 16)
+}
+
+// addCounters adds the votes, impressions and ratings of other to the image.
+func (i *Image) addCounters(other *Image) {
+	i.UpVotes += other.UpVotes
+	i.DownVotes += other.DownVotes
+	i.Impressions += other.Impressions
+	i.RatingSum += other.RatingSum
+	i.RatingCount += other.RatingCount
+}
+
+// DedupeByContent removes the images sharing the ContentKey of an earlier image, adding their counters to it.
+// It returns how many images were removed.
+func (m *ImageManager) DedupeByContent() int {
+	if m.ReadOnly() {
+		return 0
+	}
+
+	m.lockAllVotes()
+	defer m.unlockAllVotes()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := make(map[string]*Image)
+	var rs []*Image
+	for _, im := range m.images {
+		key := im.ContentKey()
+		if first, ok := kept[key]; ok {
+			first.addCounters(im)
+			continue
+		}
+		kept[key] = im
+		rs = append(rs, im)
+	}
+
+	removed := len(m.images) - len(rs)
+	m.images = rs
+	return removed
+}
+
+// URLHashID returns an id derived from the URLs of the image, so the same image always gets the same id.
+func URLHashID(image *Image) string {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\n%s", image.Thumbnail, image.Large)
+	return strconv.FormatUint(h.Sum64(), 16)
 }
 
 func cloneImage(i *Image) *Image {
@@ -227,14 +929,17 @@ func cloneImage(i *Image) *Image {
 	return &c
 }
 
-// Returns the URL to this photo in the specified size.
+// Returns the URL to this photo in the specified size, or an empty string for an unknown size.
 func (p *Photo) URL(size string) string {
-	if size == "-" {
-		return fmt.Sprintf("http://farm%s.static.flickr.com/%s/%s_%s.jpg",
-			p.Farm, p.Server, p.ID, p.Secret)
+	if !photoSizes[size] {
+		return ""
+	}
+	if size == SizeMedium500 {
+		return fmt.Sprintf("https://live.staticflickr.com/%s/%s_%s.jpg",
+			p.Server, p.ID, p.Secret)
 	}
-	return fmt.Sprintf("http://farm%s.static.flickr.com/%s/%s_%s_%s.jpg",
-		p.Farm, p.Server, p.ID, p.Secret, size)
+	return fmt.Sprintf("https://live.staticflickr.com/%s/%s_%s_%s.jpg",
+		p.Server, p.ID, p.Secret, size)
 }
 
 func (m *ImageManager) InitDB(removeDb bool) error {
@@ -242,7 +947,13 @@ func (m *ImageManager) InitDB(removeDb bool) error {
 		os.Remove("./" + DatabaseName)
 	}
 
-	db, err := sql.Open("sqlite3", "./"+DatabaseName)
+	busyTimeout := m.BusyTimeout
+	if busyTimeout <= 0 {
+		busyTimeout = DefaultBusyTimeout
+	}
+
+	// The timeout goes in the DSN rather than in a PRAGMA so that it applies to every pooled connection.
+	db, err := sql.Open("sqlite3", fmt.Sprintf("./%s?_busy_timeout=%d", DatabaseName, busyTimeout.Milliseconds()))
 	if err != nil {
 		log.Fatal(err)
 		return err
@@ -252,6 +963,27 @@ func (m *ImageManager) InitDB(removeDb bool) error {
 	return nil
 }
 
+// now returns the current time as seen by the ImageManager.
+func (m *ImageManager) now() time.Time {
+	if m.Clock != nil {
+		return m.Clock()
+	}
+	return time.Now()
+}
+
+// persistent reports whether the ImageManager persists the puppies, in its Votes or in its database.
+func (m *ImageManager) persistent() bool {
+	return m.Votes != nil || m.db != nil
+}
+
+// voteStore returns the VoteStore used by the ImageManager.
+func (m *ImageManager) voteStore() VoteStore {
+	if m.Votes != nil {
+		return m.Votes
+	}
+	return &SQLiteVoteStore{DB: m.db, SlowQueryThreshold: m.SlowQueryThreshold, Logger: m.Logger}
+}
+
 func (m *ImageManager) GetDB() *sql.DB {
 	return m.db
 }
@@ -259,73 +991,50 @@ func (m *ImageManager) GetDB() *sql.DB {
 func (m *ImageManager) CreateTables() {
 	createSqlStmt := `
 	create table if not exists votes (id integer not null primary key, puppy_id integer unique, title string, thumbnail string, large string, up_votes integer, down_votes integer);
+	create table if not exists vote_log (id integer not null primary key, puppy_id integer, up_vote boolean, voter_id string, created_at integer);
+	create table if not exists impressions (puppy_id integer not null primary key, count integer);
+	create table if not exists matches (id integer not null primary key, winner_id integer, loser_id integer, created_at integer);
+	create table if not exists expiries (puppy_id integer not null primary key, expires_at integer);
 	delete from votes;
 	`
-	_, err := m.db.Exec(createSqlStmt)
+	_, err := m.exec(createSqlStmt)
 	if err != nil {
 		log.Printf("%q: %s\n", err, createSqlStmt)
 	}
 }
 
 func (m *ImageManager) InsertPuppies(images []*Image) {
-	tx, err := m.db.Begin()
-	if err != nil {
+	if err := m.voteStore().Save(images); err != nil {
 		log.Fatal(err)
 	}
+}
 
-	stmt, err := tx.Prepare("insert into votes(puppy_id, title, thumbnail, large, up_votes, down_votes) values(?, ?, ?, ?, ?, ?)")
+// RestoreVotes copies the vote counts stored for the loaded images into them, e.g. after a restart.
+// Images without stored votes keep theirs. It returns how many images were restored.
+func (m *ImageManager) RestoreVotes() (int, error) {
+	m.lockAllVotes()
+	defer m.unlockAllVotes()
+
+	stored, err := m.voteStore().Load(imageIDs(m.All()))
 	if err != nil {
-		log.Fatal(err)
+		return 0, err
 	}
 
-	defer stmt.Close()
-
-	for _, im := range images {
-		_, err = stmt.Exec(im.ID, im.Title, im.Thumbnail, im.Large, im.UpVotes, im.DownVotes)
-		if err != nil {
-			log.Fatal(err)
+	restored := 0
+	for _, puppy := range stored {
+		if m.setVotes(puppy.ID, puppy.UpVotes, puppy.DownVotes) {
+			restored++
 		}
 	}
 
-	tx.Commit()
+	return restored, nil
 }
 
 func (m *ImageManager) FindOldPuppies(ids []string) []*Image {
-
-	//sqlStmt = "select * from votes where puppy_id in (?" + strings.Repeat(",?", len(ids)-1) + ")"
-
-	query := fmt.Sprintf("select * from votes where puppy_id in (%s)",
-		strings.Join(strings.Split(strings.Repeat("?", len(ids)), ""), ","))
-
-	stmt, err := m.db.Prepare(query)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	var params []interface{}
-	for _, id := range ids {
-		params = append(params, id)
-	}
-	defer stmt.Close()
-	rows, err := stmt.Query(params...)
+	rs, err := m.voteStore().Load(ids)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	defer rows.Close()
-
-	var rs []*Image
-
-	for rows.Next() {
-		var dbImage Image
-		var id int
-		rows.Scan(&id, &dbImage.ID, &dbImage.Title, &dbImage.Thumbnail, &dbImage.Large, &dbImage.UpVotes, &dbImage.DownVotes)
-		rs = append(rs, &dbImage)
-	}
-
-	if err = rows.Err(); err != nil {
-		log.Fatal(err)
-	}
-
 	return rs
 }
@@ -0,0 +1,752 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newTestManager returns an ImageManager on a fresh database with its tables created, in a temporary directory
+// made the working directory for the rest of the test.
+func newTestManager(t *testing.T) *ImageManager {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	m := NewImageManager()
+	if err := m.InitDB(false); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m.GetDB().Close() })
+	m.CreateTables()
+	return m
+}
+
+// saveImages saves the images into m, failing the test on error.
+func saveImages(t *testing.T, m *ImageManager, images ...*Image) {
+	t.Helper()
+	for _, im := range images {
+		if err := m.Save(im); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPersistVote(t *testing.T) {
+	m := newTestManager(t)
+	saveImages(t, m, &Image{ID: "1", Title: "Rex"})
+	m.InsertPuppies(m.All())
+
+	m.PersistVote("1", true)
+	m.PersistVote("1", true)
+	up, down, err := m.PersistVote("1", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if up != 2 || down != 1 {
+		t.Errorf("PersistVote = %d, %d, want 2, 1", up, down)
+	}
+
+	stored := m.FindOldPuppies([]string{"1"})
+	if len(stored) != 1 || stored[0].UpVotes != 2 || stored[0].DownVotes != 1 {
+		t.Errorf("stored = %v, want 2 up and 1 down votes", stored)
+	}
+}
+
+func TestPersistVoteWithoutRow(t *testing.T) {
+	m := newTestManager(t)
+	saveImages(t, m, &Image{ID: "1", Title: "Rex"})
+
+	if _, _, err := m.PersistVote("1", true); !errors.Is(err, ErrImageNotFound) {
+		t.Fatalf("PersistVote error = %v, want ErrImageNotFound", err)
+	}
+
+	if im, _ := m.Find("1"); im.UpVotes != 0 {
+		t.Errorf("UpVotes = %d after a failed persist, want 0", im.UpVotes)
+	}
+}
+
+func TestPersistVoteUnknownImage(t *testing.T) {
+	m := newTestManager(t)
+
+	if _, _, err := m.PersistVote("1", true); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("PersistVote error = %v, want ErrImageNotFound", err)
+	}
+}
+
+func TestPersistVoteReadOnly(t *testing.T) {
+	m := newTestManager(t)
+	saveImages(t, m, &Image{ID: "1"})
+	m.InsertPuppies(m.All())
+	m.SetReadOnly(true)
+
+	if _, _, err := m.PersistVote("1", true); err != ErrReadOnly {
+		t.Errorf("PersistVote error = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestConcurrentVotes(t *testing.T) {
+	m := newTestManager(t)
+	saveImages(t, m, &Image{ID: "1"})
+	m.InsertPuppies(m.All())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(up bool) {
+			defer wg.Done()
+			if _, _, err := m.PersistVote("1", up); err != nil {
+				t.Error(err)
+			}
+			m.All()
+		}(i%5 != 0)
+	}
+	wg.Wait()
+
+	im, _ := m.Find("1")
+	if im.UpVotes != 40 || im.DownVotes != 10 {
+		t.Errorf("votes = %d up, %d down, want 40 up, 10 down", im.UpVotes, im.DownVotes)
+	}
+	stored := m.FindOldPuppies([]string{"1"})
+	if stored[0].UpVotes != 40 || stored[0].DownVotes != 10 {
+		t.Errorf("stored votes = %d up, %d down, want 40 up, 10 down", stored[0].UpVotes, stored[0].DownVotes)
+	}
+}
+
+func TestConcurrentVotesTwoManagers(t *testing.T) {
+	m1 := newTestManager(t)
+	saveImages(t, m1, &Image{ID: "1"})
+	m1.InsertPuppies(m1.All())
+
+	m2 := NewImageManager()
+	if err := m2.InitDB(false); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { m2.GetDB().Close() })
+	if err := m2.LoadImages(); err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 40; i++ {
+		wg.Add(1)
+		go func(m *ImageManager) {
+			defer wg.Done()
+			if _, _, err := m.PersistVote("1", true); err != nil {
+				t.Error(err)
+			}
+		}([]*ImageManager{m1, m2}[i%2])
+	}
+	wg.Wait()
+
+	stored := m1.FindOldPuppies([]string{"1"})
+	if stored[0].UpVotes != 40 {
+		t.Errorf("stored up votes = %d, want all the 40 votes of both managers", stored[0].UpVotes)
+	}
+	if up, down, err := m2.PersistVote("1", false); err != nil || up != 40 || down != 1 {
+		t.Errorf("PersistVote = %d, %d, %v, want 40, 1 counting the votes of the other manager", up, down, err)
+	}
+}
+
+func TestUpdateCountsDownVotes(t *testing.T) {
+	m := NewImageManager()
+	saveImages(t, m, &Image{ID: "1"})
+
+	for _, up := range []bool{true, false, true, false, false} {
+		if _, _, err := m.Update(&Image{ID: "1"}, up); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if im, _ := m.Find("1"); im.UpVotes != 2 || im.DownVotes != 3 {
+		t.Errorf("votes = %d up, %d down, want 2 up, 3 down", im.UpVotes, im.DownVotes)
+	}
+}
+
+func TestUpdateIgnoresStaleCopy(t *testing.T) {
+	m := NewImageManager()
+	saveImages(t, m, &Image{ID: "1"})
+
+	stale := m.All()[0]
+	m.Update(stale, true)
+	up, _, err := m.Update(stale, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if up != 2 {
+		t.Errorf("UpVotes = %d, want 2 with a stale copy of the image", up)
+	}
+}
+
+// hookStore is a VoteStore calling afterIncrement once it has added a vote, to act meanwhile on the ImageManager.
+type hookStore struct {
+	*fakeVoteStore
+	afterIncrement func()
+}
+
+func (s hookStore) Increment(id string, up bool) (VoteCount, bool, error) {
+	counts, ok, err := s.fakeVoteStore.Increment(id, up)
+	s.afterIncrement()
+	return counts, ok, err
+}
+
+func TestUpdateRemovedImage(t *testing.T) {
+	m := NewImageManager()
+	m.Votes = hookStore{newFakeVoteStore(&Image{ID: "1"}), func() { m.DeleteMany([]string{"1"}) }}
+	saveImages(t, m, &Image{ID: "1"})
+
+	if _, _, err := m.Update(&Image{ID: "1"}, true); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("Update error = %v, want ErrImageNotFound for an image removed meanwhile", err)
+	}
+}
+
+func TestFindReturnsCopy(t *testing.T) {
+	m := NewImageManager()
+	saveImages(t, m, &Image{ID: "1"})
+
+	im, _ := m.Find("1")
+	im.UpVotes = 10
+
+	if im, _ := m.Find("1"); im.UpVotes != 0 {
+		t.Errorf("UpVotes = %d, want the stored image left alone", im.UpVotes)
+	}
+}
+
+// benchmarkVotes measures the votes cast in parallel on n images, through a store taking a while to persist them.
+// With single, every vote also holds one process-wide mutex, instead of only the vote lock of its image.
+func benchmarkVotes(b *testing.B, n int, single bool) {
+	var images []*Image
+	for i := 0; i < n; i++ {
+		images = append(images, &Image{ID: strconv.Itoa(i)})
+	}
+	store := newFakeVoteStore(images...)
+	store.delay = 50 * time.Microsecond
+
+	m := NewImageManager()
+	m.Votes = store
+	for _, im := range images {
+		m.Save(im)
+	}
+
+	var global sync.Mutex
+	var next int64
+	b.SetParallelism(8)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		id := strconv.Itoa(int(atomic.AddInt64(&next, 1)) % n)
+		for pb.Next() {
+			if single {
+				global.Lock()
+			}
+			if _, _, err := m.PersistVote(id, true); err != nil {
+				b.Error(err)
+			}
+			if single {
+				global.Unlock()
+			}
+		}
+	})
+}
+
+// BenchmarkVotesDistinctImages votes on different images, which the striped vote locks let proceed concurrently.
+func BenchmarkVotesDistinctImages(b *testing.B) { benchmarkVotes(b, 64, false) }
+
+// BenchmarkVotesSameImage votes on a single image, serializing all the votes as a manager-wide mutex would.
+func BenchmarkVotesSameImage(b *testing.B) { benchmarkVotes(b, 1, false) }
+
+// BenchmarkVotesSingleLock votes on different images behind a single process-wide lock, for comparison.
+func BenchmarkVotesSingleLock(b *testing.B) { benchmarkVotes(b, 64, true) }
+
+func TestSaveDoesNotModifyImage(t *testing.T) {
+	m := NewImageManager()
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	m.Clock = func() time.Time { return now }
+	m.IDGenerator = func(*Image) string { return "generated" }
+
+	image := &Image{Title: "Rex"}
+	if err := m.Save(image); err != nil {
+		t.Fatal(err)
+	}
+
+	if image.ID != "" || !image.AddedAt.IsZero() {
+		t.Errorf("saved image = %+v, want it left alone", *image)
+	}
+	stored, ok := m.Find("generated")
+	if !ok || !stored.AddedAt.Equal(now) {
+		t.Errorf("stored image = %+v, want the generated id and the current time", stored)
+	}
+}
+
+func TestSaveDefaultID(t *testing.T) {
+	image := &Image{Thumbnail: "https://example.com/rex_q.jpg", Large: "https://example.com/rex_b.jpg"}
+
+	var ids []string
+	for i := 0; i < 2; i++ {
+		m := NewImageManager()
+		saveImages(t, m, image)
+		all := m.All()
+		if len(all) != 1 || all[0].ID == "" {
+			t.Fatalf("images = %v, want one image with an id", imageIDs(all))
+		}
+		ids = append(ids, all[0].ID)
+	}
+
+	if ids[0] != ids[1] || ids[0] != URLHashID(image) {
+		t.Errorf("ids = %v, want the same URL hash %s", ids, URLHashID(image))
+	}
+	if other := URLHashID(&Image{Thumbnail: "https://example.com/max_q.jpg"}); other == ids[0] {
+		t.Errorf("images with other URLs share the id %s", other)
+	}
+}
+
+func TestSaveNoClone(t *testing.T) {
+	m := NewImageManager()
+	now := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	m.Clock = func() time.Time { return now }
+	m.IDGenerator = func(*Image) string { return "generated" }
+	m.NoClone = true
+
+	complete := &Image{ID: "1", AddedAt: now.Add(-time.Hour)}
+	incomplete := &Image{Title: "Rex"}
+	saveImages(t, m, complete, incomplete)
+
+	if m.images[0] != complete || m.images[1] != incomplete {
+		t.Error("NoClone stored copies of the images")
+	}
+	if incomplete.ID != "generated" || !incomplete.AddedAt.Equal(now) {
+		t.Errorf("image = %+v, want its ID and AddedAt filled in place", *incomplete)
+	}
+	if !complete.AddedAt.Equal(now.Add(-time.Hour)) {
+		t.Errorf("AddedAt = %v, want it kept", complete.AddedAt)
+	}
+}
+
+// benchmarkSave measures saving a batch of images into a fresh ImageManager, with or without NoClone.
+func benchmarkSave(b *testing.B, noClone bool) {
+	images := make([]*Image, 1000)
+	for i := range images {
+		images[i] = &Image{ID: strconv.Itoa(i), Title: "Rex", Thumbnail: "t.jpg", Large: "l.jpg"}
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		m := NewImageManager()
+		m.NoClone = noClone
+		for _, im := range images {
+			m.Save(im)
+		}
+	}
+}
+
+// BenchmarkSaveClone saves a copy of every image, as Save does by default.
+func BenchmarkSaveClone(b *testing.B) { benchmarkSave(b, false) }
+
+// BenchmarkSaveNoClone stores the images themselves, sparing a copy per image.
+func BenchmarkSaveNoClone(b *testing.B) { benchmarkSave(b, true) }
+
+func TestSaveClones(t *testing.T) {
+	m := NewImageManager()
+
+	image := &Image{ID: "1", AddedAt: time.Now()}
+	saveImages(t, m, image)
+
+	if m.images[0] == image {
+		t.Error("Save stored the passed image instead of a copy")
+	}
+	image.UpVotes = 10
+	if im, _ := m.Find("1"); im.UpVotes != 0 {
+		t.Errorf("UpVotes = %d, want the stored copy left alone", im.UpVotes)
+	}
+}
+
+func TestImportPhotosGeneratedIDs(t *testing.T) {
+	m := NewImageManager()
+	m.IDGenerator = func(im *Image) string { return "id-" + im.Title }
+
+	ids := m.ImportPhotos([]Photo{{Title: "Rex"}})
+	if len(ids) != 1 || ids[0] != "id-Rex" {
+		t.Fatalf("ImportPhotos = %v, want [id-Rex]", ids)
+	}
+	if _, ok := m.Find("id-Rex"); !ok {
+		t.Error("imported image not found by its generated id")
+	}
+}
+
+func TestImportPhotosFilter(t *testing.T) {
+	m := NewImageManager()
+	m.PhotoFilter = func(ph Photo) bool { return !strings.Contains(strings.ToLower(ph.Title), "cat") }
+
+	ids := m.ImportPhotos([]Photo{{ID: "1", Title: "Rex"}, {ID: "2", Title: "Not a Cat"}, {ID: "3", Title: "Fido"}})
+	if !reflect.DeepEqual(ids, []string{"1", "3"}) {
+		t.Errorf("ImportPhotos = %v, want [1 3]", ids)
+	}
+	if _, ok := m.Find("2"); ok {
+		t.Error("the photo with a banned word was imported")
+	}
+}
+
+func TestDedupeByContent(t *testing.T) {
+	m := NewImageManager()
+	saveImages(t, m,
+		&Image{ID: "1", Title: "Rex", Large: "https://example.com/rex_b.jpg", UpVotes: 2, Impressions: 3},
+		&Image{ID: "2", Title: "Fido", Large: "https://example.com/fido_b.jpg"},
+		&Image{ID: "3", Title: "Rex", Large: "https://example.com/rex_b.jpg", UpVotes: 1, DownVotes: 1, Impressions: 4},
+		&Image{ID: "4", Title: "Rex", Large: "https://example.com/rex2_b.jpg"})
+
+	if a, b := (&Image{ID: "1", Title: "Rex", Large: "l"}).ContentKey(), (&Image{ID: "2", Title: "Rex", Large: "l"}).ContentKey(); a != b {
+		t.Errorf("ContentKey = %s, %s, want the same key whatever the id", a, b)
+	}
+
+	if n := m.DedupeByContent(); n != 1 {
+		t.Errorf("DedupeByContent = %d, want 1", n)
+	}
+	if ids := imageIDs(m.All()); !reflect.DeepEqual(ids, []string{"1", "2", "4"}) {
+		t.Errorf("images = %v, want [1 2 4]", ids)
+	}
+	if im, _ := m.Find("1"); im.UpVotes != 3 || im.DownVotes != 1 || im.Impressions != 7 {
+		t.Errorf("kept image = %+v, want the counters of the duplicate added", *im)
+	}
+}
+
+func TestDeleteMany(t *testing.T) {
+	m := newTestManager(t)
+	saveImages(t, m, &Image{ID: "1"}, &Image{ID: "2"}, &Image{ID: "3"})
+	m.InsertPuppies(m.All())
+
+	n, err := m.DeleteMany([]string{"1", "3", "4"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("deleted = %d, want 2", n)
+	}
+
+	if ids := imageIDs(m.All()); len(ids) != 1 || ids[0] != "2" {
+		t.Errorf("images left = %v, want [2]", ids)
+	}
+	if mem, stored, err := m.Counts(); err != nil || mem != 1 || stored != 1 {
+		t.Errorf("Counts = %d, %d, %v, want 1, 1", mem, stored, err)
+	}
+}
+
+func TestCatalogETag(t *testing.T) {
+	m := NewImageManager()
+	saveImages(t, m, &Image{ID: "1"}, &Image{ID: "2"})
+
+	etag := m.CatalogETag()
+	if again := m.CatalogETag(); again != etag {
+		t.Errorf("ETag changed from %s to %s without any change", etag, again)
+	}
+
+	m.PersistVote("2", true)
+	if voted := m.CatalogETag(); voted == etag {
+		t.Errorf("ETag %s unchanged after a vote", voted)
+	}
+}
+
+func TestJSONNaming(t *testing.T) {
+	defer func(naming string) { JSONNaming = naming }(JSONNaming)
+	image := Image{ID: "1", UpVotes: 2, DownVotes: 1, CropHint: "square"}
+
+	tests := []struct {
+		naming string
+		want   string
+	}{
+		{"lower", `{"id":"1","title":"","thumbnail":"","large":"","upvotes":2,"downvotes":1,"impressions":0,"crop_hint":"square","approval":0.6667}`},
+		{"camel", `{"id":"1","title":"","thumbnail":"","large":"","upVotes":2,"downVotes":1,"impressions":0,"cropHint":"square","approval":0.6667}`},
+	}
+	for _, tt := range tests {
+		JSONNaming = tt.naming
+		got, err := json.Marshal(image)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.naming, got, tt.want)
+		}
+	}
+}
+
+func TestCamelImageKeepsImageFields(t *testing.T) {
+	image, camel := reflect.TypeOf(Image{}), reflect.TypeOf(camelImage{})
+	if image.NumField() != camel.NumField() {
+		t.Fatalf("Image has %d fields, camelImage %d", image.NumField(), camel.NumField())
+	}
+	for i := 0; i < image.NumField(); i++ {
+		if image.Field(i).Name != camel.Field(i).Name || image.Field(i).Type != camel.Field(i).Type {
+			t.Errorf("field %d: Image has %s, camelImage %s", i, image.Field(i).Name, camel.Field(i).Name)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	m := newCatalog(t, &Image{ID: "1", UpVotes: 2, Impressions: 5}, &Image{ID: "2", DownVotes: 1})
+	other := newCatalog(t, &Image{ID: "2", UpVotes: 3, DownVotes: 1}, &Image{ID: "3", UpVotes: 1})
+
+	if added := m.Merge(other); added != 1 {
+		t.Errorf("added = %d, want 1", added)
+	}
+
+	want := map[string][2]int{"1": {2, 0}, "2": {3, 2}, "3": {1, 0}}
+	if len(m.All()) != len(want) {
+		t.Fatalf("images = %v, want 1, 2 and 3", imageIDs(m.All()))
+	}
+	for id, votes := range want {
+		if im, _ := m.Find(id); im.UpVotes != votes[0] || im.DownVotes != votes[1] {
+			t.Errorf("votes of %s = %d, %d, want %d, %d", id, im.UpVotes, im.DownVotes, votes[0], votes[1])
+		}
+	}
+
+	if im, _ := other.Find("2"); im.UpVotes != 3 {
+		t.Errorf("merged manager changed: UpVotes of 2 = %d, want 3", im.UpVotes)
+	}
+	if added := m.Merge(m); added != 0 {
+		t.Errorf("merging a manager into itself added %d images", added)
+	}
+}
+
+func TestAttribution(t *testing.T) {
+	defer func(include bool) { IncludeAttribution = include }(IncludeAttribution)
+
+	image := Image{ID: "1", Owner: "12345@N00"}
+	if got := image.Attribution(); got != "Photo by 12345@N00" {
+		t.Errorf("Attribution = %q, want %q", got, "Photo by 12345@N00")
+	}
+	if got := (&Image{ID: "2"}).Attribution(); got != "" {
+		t.Errorf("Attribution without owner = %q, want none", got)
+	}
+
+	IncludeAttribution = true
+	data, _ := json.Marshal(image)
+	var decoded struct{ Attribution string }
+	json.Unmarshal(data, &decoded)
+	if decoded.Attribution != "Photo by 12345@N00" {
+		t.Errorf("JSON = %s, want the attribution", data)
+	}
+
+	IncludeAttribution = false
+	if data, _ := json.Marshal(image); strings.Contains(string(data), "attribution") {
+		t.Errorf("JSON = %s, want no attribution", data)
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	m := newTestManager(t)
+	saveImages(t, m, &Image{ID: "1"})
+	m.InsertPuppies(m.All())
+
+	m.SetReadOnly(true)
+	if err := m.UpdateVotes(1, true, "alice"); err != ErrReadOnly {
+		t.Errorf("UpdateVotes error = %v, want ErrReadOnly", err)
+	}
+	if err := m.Save(&Image{ID: "2"}); err != ErrReadOnly {
+		t.Errorf("Save error = %v, want ErrReadOnly", err)
+	}
+	if _, err := m.UndoVote(1, true, "alice"); err != ErrReadOnly {
+		t.Errorf("UndoVote error = %v, want ErrReadOnly", err)
+	}
+	if len(m.All()) != 1 {
+		t.Errorf("reads failed in read-only mode")
+	}
+
+	m.SetReadOnly(false)
+	if err := m.UpdateVotes(1, true, "alice"); err != nil {
+		t.Errorf("UpdateVotes error = %v after clearing the read-only mode", err)
+	}
+	if stored := m.FindOldPuppies([]string{"1"}); stored[0].UpVotes != 1 {
+		t.Errorf("stored UpVotes = %d, want 1", stored[0].UpVotes)
+	}
+}
+
+func TestVisibleHidesExpired(t *testing.T) {
+	m := NewImageManager()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	m.Clock = func() time.Time { return now }
+	saveImages(t, m, &Image{ID: "1"}, &Image{ID: "2"}, &Image{ID: "3"})
+
+	if err := m.SetExpiry("1", now.Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetExpiry("2", now.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetExpiry("4", now); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("SetExpiry of an unknown image error = %v, want ErrImageNotFound", err)
+	}
+
+	if ids := imageIDs(m.Visible()); !reflect.DeepEqual(ids, []string{"2", "3"}) {
+		t.Errorf("Visible = %v, want [2 3]", ids)
+	}
+
+	now = now.Add(2 * time.Hour)
+	m.SetExpiry("1", time.Time{})
+	if ids := imageIDs(m.Visible()); !reflect.DeepEqual(ids, []string{"1", "3"}) {
+		t.Errorf("Visible = %v once 2 expired and 1 cleared, want [1 3]", ids)
+	}
+}
+
+func TestStaleImages(t *testing.T) {
+	m := NewImageManager()
+	now := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+	m.Clock = func() time.Time { return now }
+	saveImages(t, m,
+		&Image{ID: "old", AddedAt: now.Add(-72 * time.Hour)},
+		&Image{ID: "recent", AddedAt: now.Add(-time.Hour)},
+		&Image{ID: "day", AddedAt: now.Add(-25 * time.Hour)})
+	// as LoadImages would, without AddedAt
+	m.images = append(m.images, &Image{ID: "loaded"})
+
+	if ids := imageIDs(m.StaleImages(24 * time.Hour)); !reflect.DeepEqual(ids, []string{"old", "day", "loaded"}) {
+		t.Errorf("StaleImages = %v, want [old day loaded]", ids)
+	}
+	if ids := imageIDs(m.StaleImages(48 * time.Hour)); !reflect.DeepEqual(ids, []string{"old", "loaded"}) {
+		t.Errorf("StaleImages = %v, want [old loaded]", ids)
+	}
+}
+
+func TestCounts(t *testing.T) {
+	m := newTestManager(t)
+	saveImages(t, m, &Image{ID: "1"}, &Image{ID: "2"}, &Image{ID: "3"})
+	m.InsertPuppies([]*Image{{ID: "1"}, {ID: "4"}})
+
+	mem, stored, err := m.Counts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mem != 3 || stored != 2 {
+		t.Errorf("Counts = %d, %d, want 3 images in memory and 2 rows", mem, stored)
+	}
+}
+
+func TestRestoreVotes(t *testing.T) {
+	m := newTestManager(t)
+	m.InsertPuppies([]*Image{{ID: "1", UpVotes: 4, DownVotes: 1}, {ID: "3", UpVotes: 2}})
+
+	// as after a restart, the images are loaded again without their votes
+	saveImages(t, m, &Image{ID: "1"}, &Image{ID: "2"})
+
+	n, err := m.RestoreVotes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("RestoreVotes = %d, want 1", n)
+	}
+	if im, _ := m.Find("1"); im.UpVotes != 4 || im.DownVotes != 1 {
+		t.Errorf("votes of 1 = %d, %d, want the stored 4, 1", im.UpVotes, im.DownVotes)
+	}
+	if im, _ := m.Find("2"); im.UpVotes != 0 || im.DownVotes != 0 {
+		t.Errorf("votes of 2 = %d, %d, want 0, 0 without a stored row", im.UpVotes, im.DownVotes)
+	}
+	if _, ok := m.Find("3"); ok {
+		t.Error("the stored puppy 3 was added to the loaded images")
+	}
+}
+
+func TestPhotoURL(t *testing.T) {
+	photo := Photo{ID: "123", Secret: "abc", Server: "65535", Farm: "66"}
+
+	tests := []struct {
+		size string
+		want string
+	}{
+		{SizeThumbnail, "https://live.staticflickr.com/65535/123_abc_t.jpg"},
+		{SizeMedium500, "https://live.staticflickr.com/65535/123_abc.jpg"},
+		{SizeMedium800, "https://live.staticflickr.com/65535/123_abc_c.jpg"},
+		{SizeLarge, "https://live.staticflickr.com/65535/123_abc_b.jpg"},
+		{SizeLarge1600, "https://live.staticflickr.com/65535/123_abc_h.jpg"},
+		{"x", ""},
+	}
+	for _, tt := range tests {
+		if got := photo.URL(tt.size); got != tt.want {
+			t.Errorf("URL(%q) = %q, want %q", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestGetPuppiesResponseInvalidField(t *testing.T) {
+	m := NewImageManager()
+
+	tests := []struct {
+		response SearchResponse
+		field    string
+	}{
+		{SearchResponse{Page: "abc", Pages: "1", PerPage: "10", Total: "3"}, "page"},
+		{SearchResponse{Page: "1", Pages: "x", PerPage: "10", Total: "3"}, "pages"},
+		{SearchResponse{Page: "1", Pages: "1", PerPage: "ten", Total: "3"}, "perpage"},
+		{SearchResponse{Page: "1", Pages: "1", PerPage: "10", Total: "3.5"}, "total"},
+	}
+	for _, tt := range tests {
+		response, err := m.GetPuppiesResponse(&tt.response)
+		if err == nil || !strings.HasPrefix(err.Error(), "parsing "+tt.field+" ") {
+			t.Errorf("GetPuppiesResponse(%+v) = %v, %v, want an error naming %s", tt.response, response, err, tt.field)
+		}
+		if !errors.Is(err, strconv.ErrSyntax) {
+			t.Errorf("GetPuppiesResponse(%+v) error = %v, want it wrapping the parse error", tt.response, err)
+		}
+	}
+}
+
+func TestGetPuppiesResponseEmptyFields(t *testing.T) {
+	response, err := NewImageManager().GetPuppiesResponse(&SearchResponse{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Page != 0 || response.Pages != 0 || response.PerPage != 0 || response.Total != 0 || len(response.Images) != 0 {
+		t.Errorf("response = %+v, want it zeroed out", *response)
+	}
+}
+
+func TestInitDBBusyTimeout(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	for _, tt := range []struct {
+		timeout time.Duration
+		want    int64
+	}{
+		{0, DefaultBusyTimeout.Milliseconds()},
+		{1500 * time.Millisecond, 1500},
+	} {
+		m := NewImageManager()
+		m.BusyTimeout = tt.timeout
+		if err := m.InitDB(false); err != nil {
+			t.Fatal(err)
+		}
+
+		var got int64
+		if err := m.GetDB().QueryRow("pragma busy_timeout").Scan(&got); err != nil {
+			t.Fatal(err)
+		}
+		m.GetDB().Close()
+		if got != tt.want {
+			t.Errorf("busy_timeout with BusyTimeout %v = %d, want %d", tt.timeout, got, tt.want)
+		}
+	}
+}
+
+func TestVoteSummaries(t *testing.T) {
+	m := newTestManager(t)
+	m.InsertPuppies([]*Image{{ID: "1", UpVotes: 3, DownVotes: 1}, {ID: "2", DownVotes: 2}})
+
+	summaries, err := m.VoteSummaries([]string{"2", "9", "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []VoteSummary{{ID: "2", Down: 2, Score: -2}, {ID: "1", Up: 3, Down: 1, Score: 2}}
+	if !reflect.DeepEqual(summaries, want) {
+		t.Errorf("VoteSummaries = %+v, want %+v", summaries, want)
+	}
+
+	b, err := json.Marshal(summaries[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != `{"id":"1","up":3,"down":1,"score":2}` {
+		t.Errorf("summary = %s, want the compact record", b)
+	}
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// FlickrPhotosetQuery is the Flickr API method listing the photos of a photoset (album).
+const FlickrPhotosetQuery = "flickr.photosets.getPhotos"
+
+// photosetPerPage is how many photos of a photoset are fetched per request, the most Flickr allows.
+const photosetPerPage = 500
+
+// PhotosetResponse is a page of the photos of a photoset.
+type PhotosetResponse struct {
+	ID      string  `xml:"id,attr"`
+	Owner   string  `xml:"owner,attr"`
+	Page    string  `xml:"page,attr"`
+	Pages   string  `xml:"pages,attr"`
+	PerPage string  `xml:"perpage,attr"`
+	Total   string  `xml:"total,attr"`
+	Photos  []Photo `xml:"photo"`
+}
+
+// ImportPhotoset saves an image for each of the photos of the Flickr photoset accepted by the PhotoFilter,
+// fetching up to DefaultMaxPages pages with the HTTPClient. It returns how many images were added.
+func (m *ImageManager) ImportPhotoset(apiKey, photosetID string) (int, error) {
+	added := 0
+	for page := 1; page <= DefaultMaxPages; page++ {
+		photoset, err := m.photosetPage(apiKey, photosetID, page)
+		if err != nil {
+			return added, err
+		}
+
+		for _, ph := range photoset.Photos {
+			if ph.Owner == "" {
+				ph.Owner = photoset.Owner
+			}
+			if m.PhotoFilter != nil && !m.PhotoFilter(ph) {
+				continue
+			}
+
+			img := m.NewImage(ph)
+			if _, ok := m.Find(img.ID); ok {
+				continue
+			}
+			if err := m.Save(img); err != nil {
+				return added, err
+			}
+			added++
+		}
+
+		if pages, err := atoiOrZero(photoset.Pages); err != nil || page >= pages {
+			break
+		}
+	}
+
+	return added, nil
+}
+
+// photosetPage fetches the given page of the photos of a photoset.
+func (m *ImageManager) photosetPage(apiKey, photosetID string, page int) (*PhotosetResponse, error) {
+	params := url.Values{}
+	params.Add("method", FlickrPhotosetQuery)
+	params.Add("api_key", apiKey)
+	params.Add("photoset_id", photosetID)
+	params.Add("per_page", strconv.Itoa(photosetPerPage))
+	params.Add("page", strconv.Itoa(page))
+	params.Add("extras", "geo,o_dims")
+
+	var result struct {
+		Photoset PhotosetResponse `xml:"photoset"`
+	}
+	if err := m.callFlickr(params, &result); err != nil {
+		return nil, err
+	}
+
+	return &result.Photoset, nil
+}
+
+// callFlickr calls the Flickr API with the HTTPClient and unmarshals the content of a successful response
+// into result.
+func (m *ImageManager) callFlickr(params url.Values, result interface{}) error {
+	baseUrl, err := url.Parse(FlickrEndPoint)
+	if err != nil {
+		return err
+	}
+	baseUrl.RawQuery = params.Encode()
+
+	req, err := http.NewRequest("GET", baseUrl.String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := m.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	flickrResponse := struct {
+		Stat string      `xml:"stat,attr"`
+		Err  flickrError `xml:"err"`
+	}{}
+
+	if err := unmarshalFlickr(body, &flickrResponse); err != nil {
+		return err
+	}
+
+	if flickrResponse.Stat != "ok" {
+		return flickrResponse.Err
+	}
+
+	return unmarshalFlickr(body, result)
+}
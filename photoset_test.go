@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestImportPhotoset(t *testing.T) {
+	var pages []string
+	flickr := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("method") != FlickrPhotosetQuery || q.Get("photoset_id") != "72157" || q.Get("api_key") != "key" {
+			t.Errorf("request = %s, want the photos of photoset 72157", r.URL)
+		}
+		pages = append(pages, q.Get("page"))
+
+		page := q.Get("page")
+		fmt.Fprintf(w, `<rsp stat="ok"><photoset id="72157" owner="alice" page="%s" pages="2" perpage="500" total="3">`, page)
+		if page == "1" {
+			fmt.Fprint(w, `<photo id="1" secret="s" server="1" title="Rex"/><photo id="2" secret="s" server="1" title="Fido" owner="bob"/>`)
+		} else {
+			fmt.Fprint(w, `<photo id="3" secret="s" server="1" title="Max"/>`)
+		}
+		fmt.Fprint(w, `</photoset></rsp>`)
+	})
+
+	m := newCatalog(t, &Image{ID: "2"})
+	m.HTTPClient = stubClient(flickr)
+
+	added, err := m.ImportPhotoset("key", "72157")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 2 {
+		t.Errorf("ImportPhotoset = %d, want 2 new images", added)
+	}
+	if !reflect.DeepEqual(pages, []string{"1", "2"}) {
+		t.Errorf("pages fetched = %v, want [1 2]", pages)
+	}
+	if ids := imageIDs(m.All()); !reflect.DeepEqual(ids, []string{"2", "1", "3"}) {
+		t.Errorf("images = %v, want [2 1 3]", ids)
+	}
+	if im, _ := m.Find("1"); im.Title != "Rex" || im.Owner != "alice" || im.Thumbnail != "https://live.staticflickr.com/1/1_s_t.jpg" {
+		t.Errorf("imported image = %+v, want Rex of the owner of the photoset", *im)
+	}
+}
+
+func TestImportPhotosetError(t *testing.T) {
+	m := NewImageManager()
+	m.HTTPClient = stubClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<rsp stat="fail"><err code="1" msg="Photoset not found"/></rsp>`)
+	}))
+
+	if _, err := m.ImportPhotoset("key", "0"); err == nil || err.Error() != "flickr error 1: Photoset not found" {
+		t.Errorf("ImportPhotoset error = %v, want the flickr error", err)
+	}
+}
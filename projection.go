@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// projectedResponse is a PuppiesResponse whose images only hold some of their fields.
+type projectedResponse struct {
+	*PuppiesResponse
+	Images []map[string]json.RawMessage `json:"images"`
+}
+
+// marshalResponse encodes the response, keeping only the comma-separated JSON fields of the images when fields
+// is not empty. Field names are matched ignoring case; unknown ones are ignored.
+func marshalResponse(response *PuppiesResponse, fields string) ([]byte, error) {
+	if fields == "" {
+		return json.Marshal(response)
+	}
+
+	wanted := make(map[string]bool)
+	for _, f := range strings.Split(fields, ",") {
+		wanted[strings.ToLower(strings.TrimSpace(f))] = true
+	}
+
+	projected := projectedResponse{PuppiesResponse: response, Images: []map[string]json.RawMessage{}}
+	for _, im := range response.Images {
+		b, err := json.Marshal(im)
+		if err != nil {
+			return nil, err
+		}
+
+		var all map[string]json.RawMessage
+		if err := json.Unmarshal(b, &all); err != nil {
+			return nil, err
+		}
+
+		image := make(map[string]json.RawMessage)
+		for name, value := range all {
+			if wanted[strings.ToLower(name)] {
+				image[name] = value
+			}
+		}
+		projected.Images = append(projected.Images, image)
+	}
+
+	return json.Marshal(projected)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// decodeImages decodes the total and the images, as maps of their fields, of the encoded response.
+func decodeImages(t *testing.T, b []byte) (int, []map[string]interface{}) {
+	t.Helper()
+	var response struct {
+		Total  int                      `json:"total"`
+		Images []map[string]interface{} `json:"images"`
+	}
+	if err := json.Unmarshal(b, &response); err != nil {
+		t.Fatalf("%v: %s", err, b)
+	}
+	return response.Total, response.Images
+}
+
+func TestMarshalResponseProjection(t *testing.T) {
+	response := &PuppiesResponse{Total: 1, Images: []*Image{{ID: "1", Title: "Rex", Thumbnail: "t.jpg", UpVotes: 2}}}
+
+	b, err := marshalResponse(response, "id, Title,bogus")
+	if err != nil {
+		t.Fatal(err)
+	}
+	total, images := decodeImages(t, b)
+	if want := []map[string]interface{}{{"id": "1", "title": "Rex"}}; !reflect.DeepEqual(images, want) {
+		t.Errorf("images = %v, want %v", images, want)
+	}
+	if total != 1 {
+		t.Errorf("total = %d, want the rest of the response kept", total)
+	}
+}
+
+func TestMarshalResponseAllFields(t *testing.T) {
+	response := &PuppiesResponse{Total: 1, Images: []*Image{{ID: "1", Title: "Rex", Thumbnail: "t.jpg", UpVotes: 2}}}
+
+	b, err := marshalResponse(response, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := json.Marshal(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(b) != string(want) {
+		t.Errorf("response = %s, want %s", b, want)
+	}
+	if _, images := decodeImages(t, b); len(images) != 1 || images[0]["thumbnail"] != "t.jpg" || images[0]["upvotes"] != 2.0 {
+		t.Errorf("images = %v, want all the fields", images)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+// QualityHalfLife is how long after its last vote the recency signal of an image halves.
+const QualityHalfLife = 24 * time.Hour
+
+// wilsonZ is the z-score of the 95% confidence level used by wilsonLowerBound.
+const wilsonZ = 1.96
+
+// QualityWeights weigh the signals blended by QualityScore.
+type QualityWeights struct {
+	Wilson     float64
+	Recency    float64
+	Engagement float64
+}
+
+// DefaultQualityWeights are the QualityWeights used when the ImageManager doesn't set any.
+var DefaultQualityWeights = QualityWeights{Wilson: 0.6, Recency: 0.2, Engagement: 0.2}
+
+// wilsonLowerBound returns the lower bound of the Wilson score interval of the share of up votes,
+// which stays low for images with few votes whatever their share.
+func wilsonLowerBound(up, down int) float64 {
+	n := float64(up + down)
+	if n == 0 {
+		return 0
+	}
+
+	p := float64(up) / n
+	z2 := wilsonZ * wilsonZ
+	return (p + z2/(2*n) - wilsonZ*math.Sqrt((p*(1-p)+z2/(4*n))/n)) / (1 + z2/n)
+}
+
+// qualityScore blends the signals of an image, each between 0 and 1, into its quality score:
+//
+//	Wilson*wilson + Recency*0.5^(hours since last vote / QualityHalfLife) + Engagement*min(votes/impressions, 1)
+//
+// where wilson is the Wilson lower bound of its share of up votes. The recency of an image never voted on is 0,
+// and so is the engagement of an image never served.
+func qualityScore(image *Image, lastVote int64, weights QualityWeights, now time.Time) float64 {
+	recency := 0.0
+	if lastVote > 0 {
+		recency = math.Pow(0.5, now.Sub(time.Unix(lastVote, 0)).Hours()/QualityHalfLife.Hours())
+	}
+
+	engagement := 0.0
+	if image.Impressions > 0 {
+		engagement = math.Min(float64(image.UpVotes+image.DownVotes)/float64(image.Impressions), 1)
+	}
+
+	return weights.Wilson*wilsonLowerBound(image.UpVotes, image.DownVotes) +
+		weights.Recency*recency + weights.Engagement*engagement
+}
+
+// QualityScore returns the quality score of the image, weighing its signals by the QualityWeights of the
+// ImageManager; see qualityScore for the formula. It returns ErrImageNotFound for an unknown id.
+func (m *ImageManager) QualityScore(id string) (float64, error) {
+	if _, ok := m.Find(id); !ok {
+		return 0, ErrImageNotFound
+	}
+
+	scores, err := m.qualityScores()
+	if err != nil {
+		return 0, err
+	}
+
+	return scores[id], nil
+}
+
+// TopByQuality returns the n images with the highest quality score.
+func (m *ImageManager) TopByQuality(n int) []*Image {
+	scores, err := m.qualityScores()
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	rs := m.sortByScore(m.All())
+	sort.SliceStable(rs, func(i, j int) bool {
+		return scores[rs[i].ID] > scores[rs[j].ID]
+	})
+
+	return firstN(rs, n)
+}
+
+// qualityScores returns the quality score of every image, keyed by id.
+func (m *ImageManager) qualityScores() (map[string]float64, error) {
+	lastVotes, err := m.voteTimes("max")
+	if err != nil {
+		return nil, err
+	}
+
+	weights := m.QualityWeights
+	if weights == (QualityWeights{}) {
+		weights = DefaultQualityWeights
+	}
+
+	now := m.now()
+	scores := make(map[string]float64)
+	for _, im := range m.All() {
+		scores[im.ID] = qualityScore(im, lastVotes[im.ID], weights, now)
+	}
+
+	return scores, nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTopByQualityWeights(t *testing.T) {
+	m := newTestManager(t)
+	now := time.Now()
+	fixedClock(m, now)
+	saveImages(t, m, &Image{ID: "1", UpVotes: 20, Impressions: 1000}, &Image{ID: "2", UpVotes: 2, DownVotes: 2, Impressions: 4})
+	logVoteAt(t, m, now.Add(-30*24*time.Hour), 1, true, "a")
+	logVoteAt(t, m, now, 2, true, "a")
+
+	m.QualityWeights = QualityWeights{Wilson: 1}
+	if ids := imageIDs(m.TopByQuality(2)); !reflect.DeepEqual(ids, []string{"1", "2"}) {
+		t.Errorf("TopByQuality weighing the Wilson score = %v, want the well liked image first", ids)
+	}
+
+	m.QualityWeights = QualityWeights{Recency: 0.5, Engagement: 0.5}
+	if ids := imageIDs(m.TopByQuality(2)); !reflect.DeepEqual(ids, []string{"2", "1"}) {
+		t.Errorf("TopByQuality weighing recency and engagement = %v, want the recently voted image first", ids)
+	}
+	if score, err := m.QualityScore("2"); err != nil || score < 0.99 || score > 1 {
+		t.Errorf("QualityScore = %v, %v, want about 1 for a vote now on every impression", score, err)
+	}
+	if _, err := m.QualityScore("3"); err != ErrImageNotFound {
+		t.Errorf("QualityScore of an unknown image error = %v, want ErrImageNotFound", err)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"math/rand"
+	"time"
+)
+
+// SetRandSource sets the source of the randomized methods of the ImageManager (Random, WeightedRandom and NextPair),
+// e.g. to a fixed seed in tests. It defaults to a source seeded with the time of the first use.
+// PuppyOfTheDay doesn't use it, since it must pick the same puppy for a day across restarts.
+func (m *ImageManager) SetRandSource(src rand.Source) {
+	m.randMu.Lock()
+	defer m.randMu.Unlock()
+
+	m.rand = rand.New(src)
+}
+
+// intn returns a random number in [0, n) drawn from the source of the ImageManager.
+func (m *ImageManager) intn(n int) int {
+	m.randMu.Lock()
+	defer m.randMu.Unlock()
+
+	if m.rand == nil {
+		m.rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return m.rand.Intn(n)
+}
+
+// Random returns an image picked at random. It returns false for an empty catalog.
+func (m *ImageManager) Random() (*Image, bool) {
+	images := m.All()
+	if len(images) == 0 {
+		return nil, false
+	}
+
+	return images[m.intn(len(images))], true
+}
+
+// WeightedRandom returns an image picked at random, each image weighing one more than its positive net score
+// so that well-liked puppies come up more often while the others still get a chance.
+// It returns false for an empty catalog.
+func (m *ImageManager) WeightedRandom() (*Image, bool) {
+	images := m.All()
+	if len(images) == 0 {
+		return nil, false
+	}
+
+	weight := func(im *Image) int {
+		if score := im.Score(); score > 0 {
+			return score + 1
+		}
+		return 1
+	}
+
+	total := 0
+	for _, im := range images {
+		total += weight(im)
+	}
+
+	n := m.intn(total)
+	for _, im := range images {
+		if n -= weight(im); n < 0 {
+			return im, true
+		}
+	}
+	return images[len(images)-1], true
+}
+
+// NextPair returns two different images picked at random, to be compared head to head.
+// It returns false when the catalog has fewer than two images.
+func (m *ImageManager) NextPair() (*Image, *Image, bool) {
+	images := m.All()
+	if len(images) < 2 {
+		return nil, nil, false
+	}
+
+	i := m.intn(len(images))
+	j := m.intn(len(images) - 1)
+	if j >= i {
+		j++
+	}
+	return images[i], images[j], true
+}
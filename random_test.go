@@ -0,0 +1,53 @@
+package main
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+)
+
+// randomPicks returns the ids picked by the randomized methods of a manager of ten images seeded with seed.
+func randomPicks(t *testing.T, seed int64) []string {
+	m := NewImageManager()
+	for i := 0; i < 10; i++ {
+		saveImages(t, m, &Image{ID: strconv.Itoa(i), UpVotes: i})
+	}
+	m.SetRandSource(rand.NewSource(seed))
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		im, _ := m.Random()
+		weighted, _ := m.WeightedRandom()
+		a, b, _ := m.NextPair()
+		if a.ID == b.ID {
+			t.Errorf("NextPair returned %s twice", a.ID)
+		}
+		ids = append(ids, im.ID, weighted.ID, a.ID, b.ID)
+	}
+	return ids
+}
+
+func TestRandSourceDeterministic(t *testing.T) {
+	first := randomPicks(t, 42)
+	second := randomPicks(t, 42)
+
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("picks with the same seed differ: %v and %v", first, second)
+		}
+	}
+}
+
+func TestRandomEmptyCatalog(t *testing.T) {
+	m := NewImageManager()
+
+	if _, ok := m.Random(); ok {
+		t.Error("Random found an image in an empty catalog")
+	}
+	if _, ok := m.WeightedRandom(); ok {
+		t.Error("WeightedRandom found an image in an empty catalog")
+	}
+	if _, _, ok := m.NextPair(); ok {
+		t.Error("NextPair found a pair in an empty catalog")
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	l := NewRateLimiter(2, 3)
+	l.last = now
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if !l.Allow() {
+			t.Fatalf("vote %d of the burst rejected", i+1)
+		}
+	}
+	if l.Allow() {
+		t.Fatal("vote past the burst allowed")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if !l.Allow() {
+		t.Error("vote rejected after the bucket refilled")
+	}
+	if l.Allow() {
+		t.Error("second vote allowed after refilling a single token")
+	}
+}
+
+func TestUpdateVotesRateLimited(t *testing.T) {
+	m := newTestManager(t)
+	m.InsertPuppies([]*Image{{ID: "1"}})
+	m.VoteLimiter = NewRateLimiter(0, 1)
+
+	if err := m.UpdateVotes(1, true, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.UpdateVotes(1, true, "alice"); err != ErrRateLimited {
+		t.Errorf("error = %v, want ErrRateLimited", err)
+	}
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// VoteScale is the kind of votes the images receive.
+type VoteScale int
+
+const (
+	// UpDownScale votes are likes and dislikes, counted in UpVotes and DownVotes.
+	UpDownScale VoteScale = iota
+
+	// StarScale votes are ratings from MinStars to MaxStars, accumulated in RatingSum and RatingCount.
+	StarScale
+)
+
+const (
+	MinStars = 1
+	MaxStars = 5
+)
+
+// Rate adds a rating of stars to the image. It requires the ImageManager to use the StarScale.
+func (m *ImageManager) Rate(id string, stars int) error {
+	if m.ReadOnly() {
+		return ErrReadOnly
+	}
+
+	if m.VoteScale != StarScale {
+		return errors.New("ratings require the star vote scale")
+	}
+
+	if stars < MinStars || stars > MaxStars {
+		return fmt.Errorf("stars must be between %d and %d, got %d", MinStars, MaxStars, stars)
+	}
+
+	lock := m.voteLock(id)
+	lock.Lock()
+	defer lock.Unlock()
+
+	if !m.modify(id, func(im *Image) {
+		im.RatingSum += stars
+		im.RatingCount++
+	}) {
+		return ErrImageNotFound
+	}
+	return nil
+}
+
+// AverageRating returns the mean star rating of the image, or 0 when it is unknown or was never rated.
+func (m *ImageManager) AverageRating(id string) float64 {
+	image, ok := m.Find(id)
+	if !ok || image.RatingCount == 0 {
+		return 0
+	}
+
+	return float64(image.RatingSum) / float64(image.RatingCount)
+}
@@ -0,0 +1,43 @@
+package main
+
+import "testing"
+
+func TestRate(t *testing.T) {
+	m := NewImageManager()
+	m.VoteScale = StarScale
+	saveImages(t, m, &Image{ID: "1"})
+
+	for _, stars := range []int{5, 4, 2} {
+		if err := m.Rate("1", stars); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if avg := m.AverageRating("1"); avg != 11.0/3 {
+		t.Errorf("average = %v, want %v", avg, 11.0/3)
+	}
+
+	for _, stars := range []int{MinStars - 1, MaxStars + 1} {
+		if err := m.Rate("1", stars); err == nil {
+			t.Errorf("rating of %d stars accepted", stars)
+		}
+	}
+	if im, _ := m.Find("1"); im.RatingCount != 3 {
+		t.Errorf("RatingCount = %d after invalid ratings, want 3", im.RatingCount)
+	}
+
+	if err := m.Rate("2", 3); err != ErrImageNotFound {
+		t.Errorf("unknown image error = %v, want ErrImageNotFound", err)
+	}
+}
+
+func TestRateRequiresStarScale(t *testing.T) {
+	m := NewImageManager()
+	saveImages(t, m, &Image{ID: "1"})
+
+	if err := m.Rate("1", 3); err == nil {
+		t.Error("rating accepted with the up/down scale")
+	}
+	if avg := m.AverageRating("1"); avg != 0 {
+		t.Errorf("average of an unrated image = %v, want 0", avg)
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import "time"
+
+// voteTotals holds the up and down votes of a puppy.
+type voteTotals struct {
+	up, down int
+}
+
+// logTotals returns the vote totals of the puppies in the vote_log, keyed by puppy id.
+// A non-empty filter is a where clause restricting the puppies, whose placeholders are bound to args.
+func (m *ImageManager) logTotals(filter string, args ...interface{}) (map[string]voteTotals, error) {
+	query := "select puppy_id, sum(up_vote), sum(not up_vote) from vote_log"
+	if filter != "" {
+		query += " where " + filter
+	}
+	rows, err := m.query(query+" group by puppy_id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := make(map[string]voteTotals)
+	for rows.Next() {
+		var id string
+		var t voteTotals
+		if err := rows.Scan(&id, &t.up, &t.down); err != nil {
+			return nil, err
+		}
+		totals[id] = t
+	}
+
+	return totals, rows.Err()
+}
+
+// RecomputeFromLog recalculates the up and down votes of every image, in memory and in the votes table,
+// by aggregating the vote_log; images without logged votes get 0/0. It returns how many images were corrected.
+func (m *ImageManager) RecomputeFromLog() (int, error) {
+	if m.ReadOnly() {
+		return 0, ErrReadOnly
+	}
+
+	totals, err := m.logTotals("")
+	if err != nil {
+		return 0, err
+	}
+
+	return m.applyTotals(totals, nil)
+}
+
+// ReconcileRange recalculates from the vote_log the up and down votes of the images which received votes between
+// from (included) and to (excluded), in memory and in the votes table, leaving the other images alone.
+// It returns how many images were corrected.
+func (m *ImageManager) ReconcileRange(from, to time.Time) (int, error) {
+	if m.ReadOnly() {
+		return 0, ErrReadOnly
+	}
+
+	totals, err := m.logTotals("puppy_id in (select puppy_id from vote_log where created_at >= ? and created_at < ?)",
+		from.Unix(), to.Unix())
+	if err != nil {
+		return 0, err
+	}
+
+	only := make(map[string]bool)
+	for id := range totals {
+		only[id] = true
+	}
+
+	return m.applyTotals(totals, only)
+}
+
+// applyTotals sets the vote counts of the images to totals, in the VoteStore and then in memory,
+// only for the ids in only unless it is nil. It returns how many images were corrected.
+func (m *ImageManager) applyTotals(totals map[string]voteTotals, only map[string]bool) (int, error) {
+	m.lockAllVotes()
+	defer m.unlockAllVotes()
+
+	corrected := make(map[string]bool)
+
+	stored, err := m.voteStore().LoadAll()
+	if err != nil {
+		return 0, err
+	}
+
+	var corrections []VoteCount
+	for _, im := range stored {
+		if only != nil && !only[im.ID] {
+			continue
+		}
+		if want := totals[im.ID]; im.UpVotes != want.up || im.DownVotes != want.down {
+			corrections = append(corrections, VoteCount{im.ID, want.up, want.down})
+			corrected[im.ID] = true
+		}
+	}
+
+	if err := m.voteStore().Set(corrections...); err != nil {
+		return 0, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, im := range m.images {
+		if only != nil && !only[im.ID] {
+			continue
+		}
+		if want := totals[im.ID]; im.UpVotes != want.up || im.DownVotes != want.down {
+			im.UpVotes = want.up
+			im.DownVotes = want.down
+			corrected[im.ID] = true
+		}
+	}
+
+	return len(corrected), nil
+}
+
+// CheckUniqueVotes returns the puppy ids stored more than once in the votes table, which the unique
+// constraint on puppy_id should prevent.
+func (m *ImageManager) CheckUniqueVotes() ([]int, error) {
+	rows, err := m.query("select puppy_id from votes group by puppy_id having count(*) > 1 order by puppy_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
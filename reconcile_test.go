@@ -0,0 +1,93 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// assertVotes fails the test unless the image has the given votes both in memory and in the database.
+func assertVotes(t *testing.T, m *ImageManager, id string, up, down int) {
+	t.Helper()
+	if im, ok := m.Find(id); !ok || im.UpVotes != up || im.DownVotes != down {
+		t.Errorf("votes of %s in memory = %+v, want %d, %d", id, im, up, down)
+	}
+	if stored := m.FindOldPuppies([]string{id}); len(stored) != 1 || stored[0].UpVotes != up || stored[0].DownVotes != down {
+		t.Errorf("stored votes of %s = %v, want %d, %d", id, stored, up, down)
+	}
+}
+
+func TestRecomputeFromLog(t *testing.T) {
+	m := newTestManager(t)
+	saveImages(t, m, &Image{ID: "1", UpVotes: 5}, &Image{ID: "2", DownVotes: 1}, &Image{ID: "3", UpVotes: 1})
+	m.InsertPuppies(m.All())
+
+	now := time.Now()
+	logVoteAt(t, m, now, 1, true, "a")
+	logVoteAt(t, m, now, 1, true, "b")
+	logVoteAt(t, m, now, 1, false, "c")
+	logVoteAt(t, m, now, 2, false, "a")
+
+	n, err := m.RecomputeFromLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("RecomputeFromLog = %d, want 1 and 3 corrected", n)
+	}
+	assertVotes(t, m, "1", 2, 1)
+	assertVotes(t, m, "2", 0, 1)
+	assertVotes(t, m, "3", 0, 0)
+
+	if n, err := m.RecomputeFromLog(); err != nil || n != 0 {
+		t.Errorf("second RecomputeFromLog = %d, %v, want nothing left to correct", n, err)
+	}
+}
+
+func TestCheckUniqueVotes(t *testing.T) {
+	m := newTestManager(t)
+	m.InsertPuppies([]*Image{{ID: "1"}, {ID: "2"}})
+	if ids, err := m.CheckUniqueVotes(); err != nil || len(ids) != 0 {
+		t.Errorf("CheckUniqueVotes = %v, %v, want no duplicate", ids, err)
+	}
+
+	// relax the unique constraint on puppy_id to let duplicates in
+	if _, err := m.exec(`drop table votes;
+	create table votes (id integer not null primary key, puppy_id integer, title string, thumbnail string, large string, up_votes integer, down_votes integer);
+	insert into votes(puppy_id) values (3), (1), (2), (3), (1), (3)`); err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := m.CheckUniqueVotes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []int{1, 3}) {
+		t.Errorf("CheckUniqueVotes = %v, want [1 3]", ids)
+	}
+}
+
+func TestReconcileRange(t *testing.T) {
+	m := newTestManager(t)
+	saveImages(t, m, &Image{ID: "1", UpVotes: 9}, &Image{ID: "2", UpVotes: 9}, &Image{ID: "3", UpVotes: 9})
+	m.InsertPuppies(m.All())
+
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24 * time.Hour)
+	logVoteAt(t, m, from.Add(-time.Hour), 1, true, "a")
+	logVoteAt(t, m, from.Add(time.Hour), 1, false, "b")
+	logVoteAt(t, m, to, 2, true, "a")
+	logVoteAt(t, m, from.Add(-time.Second), 2, true, "b")
+
+	n, err := m.ReconcileRange(from, to)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("ReconcileRange = %d, want 1", n)
+	}
+	// the whole vote_log of the image voted on in the range is counted
+	assertVotes(t, m, "1", 1, 1)
+	assertVotes(t, m, "2", 9, 0)
+	assertVotes(t, m, "3", 9, 0)
+}
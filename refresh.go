@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const (
+	// RefreshTimeout bounds how long a catalog refresh may take.
+	RefreshTimeout = 30 * time.Second
+
+	// RefreshPages is how many pages of search results a catalog refresh fetches.
+	RefreshPages = 3
+)
+
+// AdminToken authorizes the requests to the admin endpoints, sent as "Authorization: Bearer <token>".
+// The admin endpoints reject every request while it is empty.
+var AdminToken = ""
+
+// RefreshResult counts the outcome of a catalog refresh.
+type RefreshResult struct {
+	Added   int `json:"added"`
+	Skipped int `json:"skipped"`
+}
+
+// Refresh fetches the photos matching the options from Flickr and stores the ones accepted by the PhotoFilter
+// which are not in the ImageManager yet. The others are counted as skipped.
+func (m *ImageManager) Refresh(ctx context.Context, opts SearchOptions) (RefreshResult, error) {
+	var result RefreshResult
+
+	photos, err := SearchPhotosMulti(ctx, opts)
+	if err != nil {
+		return result, err
+	}
+
+	var added []*Image
+	for _, ph := range photos {
+		if m.PhotoFilter != nil && !m.PhotoFilter(ph) {
+			result.Skipped++
+			continue
+		}
+
+		img := m.NewImage(ph)
+		img.ID = m.idFor(img)
+		if _, ok := m.Find(img.ID); ok {
+			result.Skipped++
+			continue
+		}
+
+		if err := m.Save(img); err != nil {
+			return result, err
+		}
+		added = append(added, img)
+	}
+
+	if err := m.voteStore().Save(added); err != nil {
+		return result, err
+	}
+
+	result.Added = len(added)
+	return result, nil
+}
+
+// authorized reports whether the request carries the AdminToken.
+func authorized(r *http.Request) bool {
+	want := "Bearer " + AdminToken
+	return AdminToken != "" && subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte(want)) == 1
+}
+
+// RefreshHandler fetches new puppies from Flickr within RefreshTimeout and reports how many were added and skipped.
+func RefreshHandler(w http.ResponseWriter, r *http.Request) error {
+	if !authorized(r) {
+		return unauthorized{errors.New("invalid admin token")}
+	}
+
+	imageManager, err := openImageManager()
+	if err != nil {
+		return err
+	}
+	defer imageManager.GetDB().Close()
+
+	ctx, cancel := context.WithTimeout(r.Context(), RefreshTimeout)
+	defer cancel()
+
+	result, err := imageManager.Refresh(ctx, SearchOptions{
+		Tags:     "puppies,dogs,cute",
+		Page:     1,
+		PerPage:  10,
+		Pages:    RefreshPages,
+		CacheDir: FlickrCacheDir,
+		CacheTTL: FlickrCacheTTL,
+		Client:   FlickrClient,
+	})
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// refresh sends a refresh request with the Authorization header to the RefreshHandler.
+func refresh(authorization string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", RefreshPath, nil)
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	errorHandler(RefreshHandler).ServeHTTP(w, r)
+	return w
+}
+
+// setAdminToken sets the AdminToken for the duration of the test.
+func setAdminToken(t *testing.T, token string) {
+	old := AdminToken
+	t.Cleanup(func() { AdminToken = old })
+	AdminToken = token
+}
+
+// setFlickrClient makes the handlers send their Flickr requests to h for the duration of the test.
+func setFlickrClient(t *testing.T, h http.Handler) {
+	old := FlickrClient
+	t.Cleanup(func() { FlickrClient = old })
+	FlickrClient = stubClient(h)
+}
+
+func TestRefreshHandler(t *testing.T) {
+	m := newTestManager(t)
+	m.InsertPuppies([]*Image{{ID: "p1-1"}})
+	setAdminToken(t, "secret")
+
+	setFlickrClient(t, &flickrSearchStub{pages: RefreshPages})
+
+	w := refresh("Bearer secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", w.Code, w.Body)
+	}
+	var result RefreshResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatalf("%v: %s", err, w.Body)
+	}
+	if result != (RefreshResult{Added: 2*RefreshPages - 1, Skipped: 1}) {
+		t.Errorf("result = %+v, want every photo but p1-1 added", result)
+	}
+	if n := m.GetPuppiesCount(); n != 2*RefreshPages {
+		t.Errorf("%d puppies stored, want %d", n, 2*RefreshPages)
+	}
+}
+
+func TestRefreshHandlerUnauthorized(t *testing.T) {
+	newTestManager(t)
+
+	setFlickrClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("flickr called by an unauthorized refresh")
+	}))
+
+	setAdminToken(t, "")
+	if w := refresh("Bearer "); w.Code != http.StatusUnauthorized {
+		t.Errorf("status without an AdminToken = %d, want 401", w.Code)
+	}
+
+	setAdminToken(t, "secret")
+	for _, authorization := range []string{"", "secret", "Bearer wrong"} {
+		if w := refresh(authorization); w.Code != http.StatusUnauthorized {
+			t.Errorf("status with %q = %d, want 401", authorization, w.Code)
+		}
+	}
+}
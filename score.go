@@ -0,0 +1,26 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+)
+
+// ScorePrecision is how many decimal places a RoundedScore keeps in the JSON output.
+var ScorePrecision = 4
+
+// RoundedScore is a computed score, such as a ratio, encoded in JSON rounded to ScorePrecision decimal places.
+// The methods computing scores return a float64, converted to a RoundedScore only by the JSON output.
+type RoundedScore float64
+
+func (s RoundedScore) MarshalJSON() ([]byte, error) {
+	return json.Marshal(roundScore(float64(s), ScorePrecision))
+}
+
+// roundScore rounds f to the given number of decimal places. A negative precision leaves f unchanged.
+func roundScore(f float64, precision int) float64 {
+	if precision < 0 {
+		return f
+	}
+	scale := math.Pow(10, float64(precision))
+	return math.Round(f*scale) / scale
+}
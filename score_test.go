@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestScoreRounding(t *testing.T) {
+	m := NewImageManager()
+	saveImages(t, m, &Image{ID: "1", UpVotes: 2, DownVotes: 1, Impressions: 7})
+
+	rate, err := m.VoteThroughRate("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	im, _ := m.Find("1")
+	scores := struct {
+		Approval        RoundedScore `json:"approval"`
+		VoteThroughRate RoundedScore `json:"vote_through_rate"`
+	}{RoundedScore(im.Approval()), RoundedScore(rate)}
+
+	tests := []struct {
+		precision int
+		want      string
+	}{
+		{4, `{"approval":0.6667,"vote_through_rate":0.4286}`},
+		{2, `{"approval":0.67,"vote_through_rate":0.43}`},
+		{0, `{"approval":1,"vote_through_rate":0}`},
+	}
+
+	defer func(precision int) { ScorePrecision = precision }(ScorePrecision)
+	for _, tt := range tests {
+		ScorePrecision = tt.precision
+		got, err := json.Marshal(scores)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("precision %d: got %s, want %s", tt.precision, got, tt.want)
+		}
+	}
+}
+
+func TestListTopPuppiesApproval(t *testing.T) {
+	m := newTestManager(t)
+	m.InsertPuppies([]*Image{{ID: "1", UpVotes: 2, DownVotes: 1}})
+
+	w := httptest.NewRecorder()
+	ListTopPuppies(w, mux.SetURLVars(httptest.NewRequest("GET", "/top/0", nil), map[string]string{"page": "0"}))
+
+	if body := w.Body.String(); !strings.Contains(body, `"approval":0.6667`) {
+		t.Errorf("body = %s, want the approval rounded to 4 places", body)
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SitemapBaseURL, when set, is the base of the puppy detail pages listed in the sitemap, followed by the puppy id.
+// When empty, the sitemap links to the photo pages on Flickr.
+var SitemapBaseURL = ""
+
+type sitemapURL struct {
+	Loc string `xml:"loc"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapURL `xml:"url"`
+}
+
+// detailURL returns the address of the detail page of the image.
+func detailURL(image *Image) string {
+	if SitemapBaseURL != "" {
+		return strings.TrimSuffix(SitemapBaseURL, "/") + "/" + url.PathEscape(image.ID)
+	}
+	return "https://www.flickr.com/photo.gne?id=" + url.QueryEscape(image.ID)
+}
+
+// SitemapHandler writes a sitemap listing the detail page of every stored puppy.
+func SitemapHandler(w http.ResponseWriter, r *http.Request) error {
+	imageManager, err := openImageManager()
+	if err != nil {
+		return err
+	}
+	defer imageManager.GetDB().Close()
+
+	var set sitemapURLSet
+	for _, im := range imageManager.All() {
+		set.URLs = append(set.URLs, sitemapURL{detailURL(im)})
+	}
+
+	response, err := xml.MarshalIndent(set, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	w.Write([]byte(xml.Header))
+	w.Write(response)
+	return nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSitemapHandler(t *testing.T) {
+	defer func(base string) { SitemapBaseURL = base }(SitemapBaseURL)
+	SitemapBaseURL = "https://puppies.example.com/pups/"
+
+	m := newTestManager(t)
+	m.InsertPuppies([]*Image{{ID: "1"}, {ID: "2"}, {ID: "3"}})
+
+	w := httptest.NewRecorder()
+	if err := SitemapHandler(w, httptest.NewRequest("GET", SitemapPath, nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	var set sitemapURLSet
+	if err := xml.Unmarshal(w.Body.Bytes(), &set); err != nil {
+		t.Fatalf("%v: %s", err, w.Body)
+	}
+	if len(set.URLs) != 3 {
+		t.Fatalf("got %d <url> entries, want 3", len(set.URLs))
+	}
+	if loc := set.URLs[0].Loc; loc != "https://puppies.example.com/pups/1" {
+		t.Errorf("loc = %q, want the detail page of 1", loc)
+	}
+}
+
+func TestDetailURLDefaultsToFlickr(t *testing.T) {
+	if got := detailURL(&Image{ID: "42"}); got != "https://www.flickr.com/photo.gne?id=42" {
+		t.Errorf("detailURL = %q, want the Flickr photo page", got)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"net/url"
+	"path"
+	"strings"
+)
+
+// sizesBySide lists the photo sizes from the smallest to the largest.
+var sizesBySide = []string{SizeSmallSquare, SizeThumbnail, SizeSmall, SizeMedium500, SizeMedium640,
+	SizeMedium800, SizeLarge, SizeLarge1600, SizeOriginal}
+
+// sizeRank returns how large the photo behind a URL built by Photo.URL is, compared to the other sizes.
+// URLs which don't name a known size rank below all of them.
+func sizeRank(url string) int {
+	name := strings.TrimSuffix(path.Base(url), path.Ext(url))
+	size := SizeMedium500
+	if parts := strings.Split(name, "_"); len(parts) == 3 {
+		size = parts[2]
+	} else if len(parts) != 2 {
+		return -1
+	}
+
+	for rank, s := range sizesBySide {
+		if s == size {
+			return rank
+		}
+	}
+	return -1
+}
+
+// CanonicalizeSizes merges the images sharing an id, as happens when the same photo was saved with URLs of
+// different sizes. The first image of an id is kept, with the largest thumbnail and large URLs of the group
+// and the counters of all of them added up. It returns how many images were removed.
+func (m *ImageManager) CanonicalizeSizes() int {
+	if m.ReadOnly() {
+		return 0
+	}
+
+	m.lockAllVotes()
+	defer m.unlockAllVotes()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := make(map[string]*Image)
+	var rs []*Image
+	for _, im := range m.images {
+		first, ok := kept[im.ID]
+		if !ok {
+			kept[im.ID] = im
+			rs = append(rs, im)
+			continue
+		}
+
+		if sizeRank(im.Thumbnail) > sizeRank(first.Thumbnail) {
+			first.Thumbnail = im.Thumbnail
+		}
+		if sizeRank(im.Large) > sizeRank(first.Large) {
+			first.Large = im.Large
+		}
+		first.addCounters(im)
+	}
+
+	removed := len(m.images) - len(rs)
+	m.images = rs
+	return removed
+}
+
+// squareTolerance is how much wider than tall, or the reverse, a photo may be while still hinted as square.
+const squareTolerance = 1.2
+
+// cropHint returns the CropHint of a photo of the given dimensions, or an empty string when they are unknown.
+func cropHint(width, height int) string {
+	if width <= 0 || height <= 0 {
+		return ""
+	}
+
+	ratio := float64(width) / float64(height)
+	switch {
+	case ratio > squareTolerance:
+		return "landscape"
+	case ratio < 1/squareTolerance:
+		return "portrait"
+	default:
+		return "square"
+	}
+}
+
+// FlickrSizesQuery is the Flickr API method listing the sizes available for a photo.
+const FlickrSizesQuery = "flickr.photos.getSizes"
+
+// FetchSizes returns the URLs of the sizes available for the Flickr photo, keyed by their label such as "Thumbnail"
+// or "Large 1600", fetched with the HTTPClient. The sizes of every photo are fetched once and then cached.
+func (m *ImageManager) FetchSizes(apiKey, photoID string) (map[string]string, error) {
+	m.sizesMu.Lock()
+	cached, ok := m.sizes[photoID]
+	m.sizesMu.Unlock()
+
+	if !ok {
+		params := url.Values{}
+		params.Add("method", FlickrSizesQuery)
+		params.Add("api_key", apiKey)
+		params.Add("photo_id", photoID)
+
+		var result struct {
+			Sizes []struct {
+				Label  string `xml:"label,attr"`
+				Source string `xml:"source,attr"`
+			} `xml:"sizes>size"`
+		}
+		if err := m.callFlickr(params, &result); err != nil {
+			return nil, err
+		}
+
+		cached = make(map[string]string)
+		for _, size := range result.Sizes {
+			cached[size.Label] = size.Source
+		}
+
+		m.sizesMu.Lock()
+		if m.sizes == nil {
+			m.sizes = make(map[string]map[string]string)
+		}
+		m.sizes[photoID] = cached
+		m.sizesMu.Unlock()
+	}
+
+	sizes := make(map[string]string, len(cached))
+	for label, source := range cached {
+		sizes[label] = source
+	}
+	return sizes, nil
+}
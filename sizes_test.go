@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCanonicalizeSizes(t *testing.T) {
+	photo := Photo{ID: "1", Secret: "abc", Server: "65535"}
+	m := newCatalog(t, &Image{ID: "1", Thumbnail: photo.URL(SizeSmallSquare), Large: photo.URL(SizeLarge1600), UpVotes: 2},
+		&Image{ID: "2", Thumbnail: "t.jpg"})
+	// the same photo saved again at other sizes, as Save would refuse it
+	m.images = append(m.images,
+		&Image{ID: "1", Thumbnail: photo.URL(SizeThumbnail), Large: photo.URL(SizeMedium800), UpVotes: 1, DownVotes: 1},
+		&Image{ID: "1", Thumbnail: photo.URL(SizeSmall), Large: photo.URL(SizeLarge), Impressions: 4})
+
+	if n := m.CanonicalizeSizes(); n != 2 {
+		t.Errorf("CanonicalizeSizes = %d, want 2", n)
+	}
+	if ids := imageIDs(m.All()); !reflect.DeepEqual(ids, []string{"1", "2"}) {
+		t.Fatalf("images = %v, want [1 2]", ids)
+	}
+
+	im, _ := m.Find("1")
+	if im.Thumbnail != photo.URL(SizeSmall) || im.Large != photo.URL(SizeLarge1600) {
+		t.Errorf("URLs = %s, %s, want the largest of each", im.Thumbnail, im.Large)
+	}
+	if im.UpVotes != 3 || im.DownVotes != 1 || im.Impressions != 4 {
+		t.Errorf("counters = %d, %d, %d, want them added up", im.UpVotes, im.DownVotes, im.Impressions)
+	}
+}
+
+func TestCropHint(t *testing.T) {
+	tests := []struct {
+		width, height int
+		want          string
+	}{
+		{1024, 683, "landscape"},
+		{683, 1024, "portrait"},
+		{1000, 900, "square"},
+		{0, 0, ""},
+	}
+	for _, tt := range tests {
+		if got := cropHint(tt.width, tt.height); got != tt.want {
+			t.Errorf("cropHint(%d, %d) = %q, want %q", tt.width, tt.height, got, tt.want)
+		}
+	}
+}
+
+func TestNewImageCropHint(t *testing.T) {
+	m := NewImageManager()
+	if hint := m.NewImage(Photo{ID: "1", OriginalWidth: "800", OriginalHeight: "1200"}).CropHint; hint != "portrait" {
+		t.Errorf("CropHint = %q, want portrait", hint)
+	}
+	if hint := m.NewImage(Photo{ID: "1"}).CropHint; hint != "" {
+		t.Errorf("CropHint = %q without dimensions, want none", hint)
+	}
+}
+
+func TestFetchSizes(t *testing.T) {
+	var calls int32
+	m := NewImageManager()
+	m.HTTPClient = stubClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if q := r.URL.Query(); q.Get("method") != FlickrSizesQuery || q.Get("photo_id") != "123" {
+			t.Errorf("request = %s, want the sizes of photo 123", r.URL)
+		}
+		fmt.Fprint(w, `<rsp stat="ok"><sizes canblog="0" canprint="0" candownload="1">
+<size label="Thumbnail" width="100" height="75" source="https://live.staticflickr.com/1/123_s_t.jpg"/>
+<size label="Large 1600" width="1600" height="1200" source="https://live.staticflickr.com/1/123_s_h.jpg"/>
+</sizes></rsp>`)
+	}))
+
+	want := map[string]string{
+		"Thumbnail":  "https://live.staticflickr.com/1/123_s_t.jpg",
+		"Large 1600": "https://live.staticflickr.com/1/123_s_h.jpg",
+	}
+	for i := 0; i < 2; i++ {
+		sizes, err := m.FetchSizes("key", "123")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(sizes, want) {
+			t.Errorf("FetchSizes = %v, want %v", sizes, want)
+		}
+		sizes["Thumbnail"] = "changed"
+	}
+	if calls != 1 {
+		t.Errorf("flickr called %d times, want the sizes cached", calls)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+	"time"
+)
+
+// errNoDB is returned by the database operations of an ImageManager or a SQLiteVoteStore without a database.
+var errNoDB = errors.New("no database opened")
+
+// queryTimer warns through logger about the statements taking longer than threshold. A zero threshold disables it.
+type queryTimer struct {
+	threshold time.Duration
+	logger    *log.Logger
+}
+
+// observe warns about the statement started at start when it took more than the threshold.
+func (t queryTimer) observe(query string, start time.Time) {
+	if t.threshold <= 0 {
+		return
+	}
+	if d := time.Since(start); d > t.threshold {
+		logger := t.logger
+		if logger == nil {
+			logger = log.Default()
+		}
+		logger.Printf("slow query (%v): %s", d, strings.Join(strings.Fields(query), " "))
+	}
+}
+
+// rowScanner is the result of timedDB.QueryRow, which is a *sql.Row unless the query could not be sent.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// errRow is a rowScanner failing with err.
+type errRow struct{ err error }
+
+func (r errRow) Scan(dest ...interface{}) error { return r.err }
+
+// timedDB is a database whose statements, including the ones of its transactions and prepared statements,
+// are timed by a queryTimer. Its methods return errNoDB when db is nil.
+type timedDB struct {
+	db *sql.DB
+	queryTimer
+}
+
+func (d timedDB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	if d.db == nil {
+		return nil, errNoDB
+	}
+	defer d.observe(query, time.Now())
+	return d.db.Exec(query, args...)
+}
+
+func (d timedDB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	if d.db == nil {
+		return nil, errNoDB
+	}
+	defer d.observe(query, time.Now())
+	return d.db.Query(query, args...)
+}
+
+func (d timedDB) QueryRow(query string, args ...interface{}) rowScanner {
+	if d.db == nil {
+		return errRow{errNoDB}
+	}
+	defer d.observe(query, time.Now())
+	return d.db.QueryRow(query, args...)
+}
+
+func (d timedDB) Prepare(query string) (*timedStmt, error) {
+	if d.db == nil {
+		return nil, errNoDB
+	}
+	stmt, err := d.db.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &timedStmt{stmt, query, d.queryTimer}, nil
+}
+
+func (d timedDB) Begin() (*timedTx, error) {
+	if d.db == nil {
+		return nil, errNoDB
+	}
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &timedTx{tx, d.queryTimer}, nil
+}
+
+// timedTx is a transaction of a timedDB.
+type timedTx struct {
+	*sql.Tx
+	queryTimer
+}
+
+func (tx *timedTx) Exec(query string, args ...interface{}) (sql.Result, error) {
+	defer tx.observe(query, time.Now())
+	return tx.Tx.Exec(query, args...)
+}
+
+func (tx *timedTx) Prepare(query string) (*timedStmt, error) {
+	stmt, err := tx.Tx.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	return &timedStmt{stmt, query, tx.queryTimer}, nil
+}
+
+// timedStmt is a prepared statement of a timedDB or of one of its transactions.
+type timedStmt struct {
+	*sql.Stmt
+	query string
+	queryTimer
+}
+
+func (s *timedStmt) Exec(args ...interface{}) (sql.Result, error) {
+	defer s.observe(s.query, time.Now())
+	return s.Stmt.Exec(args...)
+}
+
+func (s *timedStmt) Query(args ...interface{}) (*sql.Rows, error) {
+	defer s.observe(s.query, time.Now())
+	return s.Stmt.Query(args...)
+}
+
+// queryTimer returns the queryTimer configured by the SlowQueryThreshold and the Logger.
+func (m *ImageManager) queryTimer() queryTimer {
+	return queryTimer{m.SlowQueryThreshold, m.Logger}
+}
+
+// sqlDB returns the database of the ImageManager, timed by its queryTimer.
+func (m *ImageManager) sqlDB() timedDB {
+	return timedDB{m.db, m.queryTimer()}
+}
+
+// exec runs a statement on the database of the ImageManager; see timedDB.
+func (m *ImageManager) exec(query string, args ...interface{}) (sql.Result, error) {
+	return m.sqlDB().Exec(query, args...)
+}
+
+// query runs a query on the database of the ImageManager; see timedDB.
+func (m *ImageManager) query(query string, args ...interface{}) (*sql.Rows, error) {
+	return m.sqlDB().Query(query, args...)
+}
+
+// queryRow runs a query returning a single row on the database of the ImageManager; see timedDB.
+func (m *ImageManager) queryRow(query string, args ...interface{}) rowScanner {
+	return m.sqlDB().QueryRow(query, args...)
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowDriver is a database driver taking slowDelay to run any statement, and returning no rows.
+type slowDriver struct{}
+
+const slowDelay = 20 * time.Millisecond
+
+type slowConn struct{}
+type slowStmt struct{}
+type slowRows struct{}
+
+func (slowDriver) Open(string) (driver.Conn, error) { return slowConn{}, nil }
+
+func (slowConn) Prepare(string) (driver.Stmt, error) { return slowStmt{}, nil }
+func (slowConn) Close() error                        { return nil }
+func (slowConn) Begin() (driver.Tx, error)           { return slowConn{}, nil }
+func (slowConn) Commit() error                       { return nil }
+func (slowConn) Rollback() error                     { return nil }
+
+func (slowStmt) Close() error  { return nil }
+func (slowStmt) NumInput() int { return -1 }
+func (slowStmt) Exec([]driver.Value) (driver.Result, error) {
+	time.Sleep(slowDelay)
+	return driver.RowsAffected(1), nil
+}
+func (slowStmt) Query([]driver.Value) (driver.Rows, error) {
+	time.Sleep(slowDelay)
+	return slowRows{}, nil
+}
+
+func (slowRows) Columns() []string         { return []string{"n"} }
+func (slowRows) Close() error              { return nil }
+func (slowRows) Next([]driver.Value) error { return io.EOF }
+
+func init() {
+	sql.Register("slowtest", slowDriver{})
+}
+
+// newSlowManager returns an ImageManager on a slowDriver database, logging the queries slower than threshold to the
+// returned buffer.
+func newSlowManager(t *testing.T, threshold time.Duration) (*ImageManager, *bytes.Buffer) {
+	db, err := sql.Open("slowtest", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	var buf bytes.Buffer
+	m := NewImageManager()
+	m.db = db
+	m.SlowQueryThreshold = threshold
+	m.Logger = log.New(&buf, "", 0)
+	return m, &buf
+}
+
+func TestSlowQueryLogged(t *testing.T) {
+	m, buf := newSlowManager(t, time.Millisecond)
+
+	if _, err := m.exec("insert into  vote_log\n\t(puppy_id) values(?)", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	got := buf.String()
+	if !strings.Contains(got, "slow query") || !strings.Contains(got, "insert into vote_log (puppy_id) values(?)") {
+		t.Errorf("log = %q, want a slow query warning with the compacted query", got)
+	}
+}
+
+func TestSlowQueryLoggedInTransactionsAndStatements(t *testing.T) {
+	m, buf := newSlowManager(t, time.Millisecond)
+
+	tx, err := m.sqlDB().Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx.Exec("update votes set up_votes = 1"); err != nil {
+		t.Fatal(err)
+	}
+	stmt, err := tx.Prepare("update votes set down_votes = ?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stmt.Exec(2); err != nil {
+		t.Fatal(err)
+	}
+	stmt.Close()
+	tx.Commit()
+
+	store := &SQLiteVoteStore{DB: m.db, SlowQueryThreshold: time.Millisecond, Logger: m.Logger}
+	if _, _, err := store.Increment("1", true); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, query := range []string{"set up_votes = 1", "set down_votes = ?", "up_votes = up_votes + 1"} {
+		if !strings.Contains(buf.String(), query) {
+			t.Errorf("log = %q, want a warning for %q", buf.String(), query)
+		}
+	}
+}
+
+func TestFastQueryNotLogged(t *testing.T) {
+	m, buf := newSlowManager(t, time.Hour)
+
+	if _, err := m.exec("delete from votes"); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("log = %q, want nothing below the threshold", buf.String())
+	}
+}
+
+func TestSlowQueryDisabled(t *testing.T) {
+	m, buf := newSlowManager(t, 0)
+
+	if _, err := m.exec("delete from votes"); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("log = %q, want nothing without a threshold", buf.String())
+	}
+}
+
+func TestNoDBReturnsError(t *testing.T) {
+	m := NewImageManager()
+	if err := m.Save(&Image{ID: "1", UpVotes: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.QualityScore("1"); !errors.Is(err, errNoDB) {
+		t.Errorf("QualityScore error = %v, want errNoDB", err)
+	}
+	if _, err := m.TrendingScore("1", time.Hour); !errors.Is(err, errNoDB) {
+		t.Errorf("TrendingScore error = %v, want errNoDB", err)
+	}
+	if _, _, err := m.VoterActivity("alice"); !errors.Is(err, errNoDB) {
+		t.Errorf("VoterActivity error = %v, want errNoDB", err)
+	}
+	if _, err := m.SentimentTrend(7); !errors.Is(err, errNoDB) {
+		t.Errorf("SentimentTrend error = %v, want errNoDB", err)
+	}
+	if _, err := m.WinMatrix([]string{"1"}); !errors.Is(err, errNoDB) {
+		t.Errorf("WinMatrix error = %v, want errNoDB", err)
+	}
+	if rs := m.RecentlyPositive(time.Hour); rs != nil {
+		t.Errorf("RecentlyPositive = %v, want nil", rs)
+	}
+	if rs := m.MostImproved(time.Hour, 1); rs != nil {
+		t.Errorf("MostImproved = %v, want nil", rs)
+	}
+}
@@ -68,6 +68,7 @@
 				var vote = {};
 				vote.ID = pup.id;
 				vote.VT = true;
+				vote.token = $scope.response.token;
 
 				votePuppy(vote);
 			}
@@ -82,6 +83,7 @@
 				var vote = {};
 				vote.ID = pup.id;
 				vote.VT = false;
+				vote.token = $scope.response.token;
 
 				votePuppy(vote);
 			}
@@ -176,6 +178,7 @@ puppyControllers.controller('TopPuppyCtrl', ['$scope', 'Puppy', 'ngDialog', func
 				var vote = {};
 				vote.ID = pup.id;
 				vote.VT = true;
+				vote.token = $scope.response.token;
 
 				votePuppy(vote);
 			}
@@ -190,6 +193,7 @@ puppyControllers.controller('TopPuppyCtrl', ['$scope', 'Puppy', 'ngDialog', func
 				var vote = {};
 				vote.ID = pup.id;
 				vote.VT = false;
+				vote.token = $scope.response.token;
 
 				votePuppy(vote);
 			}
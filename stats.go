@@ -0,0 +1,339 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// AverageVotesPerImage returns the total number of votes divided by the number of images.
+// An empty catalog has an average of 0.
+func (m *ImageManager) AverageVotesPerImage() float64 {
+	images := m.All()
+	if len(images) == 0 {
+		return 0
+	}
+
+	total := 0
+	for _, im := range images {
+		total += im.UpVotes + im.DownVotes
+	}
+
+	return float64(total) / float64(len(images))
+}
+
+// ClosestToScore returns the image whose net score is nearest to target.
+// Ties are broken by the tieBreak.
+func (m *ImageManager) ClosestToScore(target int) (*Image, bool) {
+	var closest *Image
+	best := 0
+	for _, im := range m.All() {
+		diff := abs(im.Score() - target)
+		if closest == nil || diff < best || (diff == best && m.tieBreak(im, closest)) {
+			closest = im
+			best = diff
+		}
+	}
+
+	return closest, closest != nil
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// DuplicateTitles groups the images sharing the same title, ignoring case and surrounding spaces.
+// Only titles used by more than one image are returned.
+func (m *ImageManager) DuplicateTitles() map[string][]*Image {
+	groups := make(map[string][]*Image)
+	for _, im := range m.All() {
+		title := strings.ToLower(strings.TrimSpace(im.Title))
+		groups[title] = append(groups[title], im)
+	}
+
+	for title, images := range groups {
+		if len(images) < 2 {
+			delete(groups, title)
+		}
+	}
+
+	return groups
+}
+
+// RankDelta returns, for every image, how many leaderboard places it moved up since the old snapshot
+// (negative when it moved down). Images missing from the old snapshot are ranked as if they had been
+// just below its last place; images that have since been removed are not reported.
+func (m *ImageManager) RankDelta(old []Image) map[string]int {
+	previous := make([]*Image, len(old))
+	for i := range old {
+		previous[i] = &old[i]
+	}
+
+	oldRanks := make(map[string]int)
+	for rank, im := range m.sortByScore(previous) {
+		oldRanks[im.ID] = rank
+	}
+
+	delta := make(map[string]int)
+	for rank, im := range m.sortByScore(m.All()) {
+		oldRank, ok := oldRanks[im.ID]
+		if !ok {
+			oldRank = len(old)
+		}
+		delta[im.ID] = oldRank - rank
+	}
+
+	return delta
+}
+
+// sortByScore returns a copy of images ordered by descending net score, then as decided by the tieBreak.
+// The order is the same whatever the order of images, so the rankings re-sorting it with a stable sort are
+// deterministic as well.
+func (m *ImageManager) sortByScore(images []*Image) []*Image {
+	sorted := make([]*Image, len(images))
+	copy(sorted, images)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Score() != sorted[j].Score() {
+			return sorted[i].Score() > sorted[j].Score()
+		}
+		return m.tieBreak(sorted[i], sorted[j])
+	})
+
+	return sorted
+}
+
+// tieBreak reports whether a ranks before b when both have the same score, using the TieBreaker
+// of the ImageManager or else favouring the image with more up votes. Images it can't tell apart
+// are ordered by id.
+func (m *ImageManager) tieBreak(a, b *Image) bool {
+	less := func(a, b *Image) bool { return a.UpVotes > b.UpVotes }
+	if m.TieBreaker != nil {
+		less = m.TieBreaker
+	}
+
+	if less(a, b) {
+		return true
+	}
+	if less(b, a) {
+		return false
+	}
+	return a.ID < b.ID
+}
+
+// VoteGini returns the Gini coefficient of the total votes received by the images:
+// 0 when votes are spread evenly, approaching 1 as they concentrate on a single image.
+// Catalogs with fewer than two images or without any vote have a coefficient of 0.
+func (m *ImageManager) VoteGini() float64 {
+	images := m.All()
+	n := len(images)
+	if n < 2 {
+		return 0
+	}
+
+	totals := make([]int, n)
+	sum := 0
+	for i, im := range images {
+		totals[i] = im.UpVotes + im.DownVotes
+		sum += totals[i]
+	}
+	if sum == 0 {
+		return 0
+	}
+	sort.Ints(totals)
+
+	weighted := 0
+	for i, total := range totals {
+		weighted += (i + 1) * total
+	}
+
+	return 2*float64(weighted)/(float64(n)*float64(sum)) - float64(n+1)/float64(n)
+}
+
+// ByScoreRange returns the images whose net score is between low and high, inclusive.
+func (m *ImageManager) ByScoreRange(low, high int) ([]*Image, error) {
+	if low > high {
+		return nil, fmt.Errorf("invalid score range [%d,%d]", low, high)
+	}
+
+	var rs []*Image
+	for _, im := range m.All() {
+		if score := im.Score(); score >= low && score <= high {
+			rs = append(rs, im)
+		}
+	}
+
+	return rs, nil
+}
+
+// firstN returns the first n images, or all of them when there are fewer.
+func firstN(images []*Image, n int) []*Image {
+	if n < 0 {
+		n = 0
+	}
+	if n < len(images) {
+		return images[:n]
+	}
+	return images
+}
+
+// VotePercentile returns the total vote count below which p percent of the images fall, using the nearest-rank
+// method. p is clamped to [0, 100]; an empty catalog gives 0.
+func (m *ImageManager) VotePercentile(p float64) int {
+	images := m.All()
+	if len(images) == 0 {
+		return 0
+	}
+
+	totals := make([]int, len(images))
+	for i, im := range images {
+		totals[i] = im.UpVotes + im.DownVotes
+	}
+	sort.Ints(totals)
+
+	rank := int(math.Ceil(p / 100 * float64(len(totals))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(totals) {
+		rank = len(totals)
+	}
+
+	return totals[rank-1]
+}
+
+// MostDecisive returns the n images with the widest gap between up and down votes,
+// among the images with at least minVotes votes in total.
+func (m *ImageManager) MostDecisive(n, minVotes int) []*Image {
+	var rs []*Image
+	for _, im := range m.sortByScore(m.All()) {
+		if im.UpVotes+im.DownVotes >= minVotes {
+			rs = append(rs, im)
+		}
+	}
+
+	sort.SliceStable(rs, func(i, j int) bool {
+		return abs(rs[i].Score()) > abs(rs[j].Score())
+	})
+
+	return firstN(rs, n)
+}
+
+// HasQuorum reports whether the image received at least min votes, making its rating reliable.
+// It returns ErrImageNotFound for an unknown id.
+func (m *ImageManager) HasQuorum(id string, min int) (bool, error) {
+	image, ok := m.Find(id)
+	if !ok {
+		return false, ErrImageNotFound
+	}
+
+	return image.UpVotes+image.DownVotes >= min, nil
+}
+
+// Leaderboard returns the n images with the highest net score among the ones reaching a quorum of votes.
+func (m *ImageManager) Leaderboard(n, quorum int) []*Image {
+	var rs []*Image
+	for _, im := range m.sortByScore(m.All()) {
+		if im.UpVotes+im.DownVotes >= quorum {
+			rs = append(rs, im)
+		}
+	}
+
+	return firstN(rs, n)
+}
+
+// GroupByInitial groups the images by the uppercased first letter of their title, for an A-Z index.
+// Titles which are empty or don't start with a letter are grouped under "#".
+func (m *ImageManager) GroupByInitial() map[string][]*Image {
+	groups := make(map[string][]*Image)
+	for _, im := range m.All() {
+		initial := "#"
+		if r, _ := utf8.DecodeRuneInString(strings.TrimSpace(im.Title)); unicode.IsLetter(r) {
+			initial = string(unicode.ToUpper(r))
+		}
+		groups[initial] = append(groups[initial], im)
+	}
+
+	return groups
+}
+
+// ClosestPair returns the two images whose net scores are the nearest, for a "too close to call" comparison,
+// the higher-scored one first. It returns false when there are fewer than two images.
+func (m *ImageManager) ClosestPair() (*Image, *Image, bool) {
+	sorted := m.sortByScore(m.All())
+	if len(sorted) < 2 {
+		return nil, nil, false
+	}
+
+	best := 1
+	for i := 2; i < len(sorted); i++ {
+		if sorted[i-1].Score()-sorted[i].Score() < sorted[best-1].Score()-sorted[best].Score() {
+			best = i
+		}
+	}
+
+	return sorted[best-1], sorted[best], true
+}
+
+// Approval returns the share of up votes among the votes of the image, or 0 when it has none.
+func (im *Image) Approval() float64 {
+	total := im.UpVotes + im.DownVotes
+	if total == 0 {
+		return 0
+	}
+	return float64(im.UpVotes) / float64(total)
+}
+
+// TopByRatio returns the n images with the highest share of up votes among the ones with at least minVotes
+// votes in total, so that images with a single up vote don't dominate. Ties go to the image with more votes.
+func (m *ImageManager) TopByRatio(n, minVotes int) []*Image {
+	var rs []*Image
+	for _, im := range m.sortByScore(m.All()) {
+		if total := im.UpVotes + im.DownVotes; total > 0 && total >= minVotes {
+			rs = append(rs, im)
+		}
+	}
+
+	sort.SliceStable(rs, func(i, j int) bool {
+		if rs[i].Approval() != rs[j].Approval() {
+			return rs[i].Approval() > rs[j].Approval()
+		}
+		return rs[i].UpVotes+rs[i].DownVotes > rs[j].UpVotes+rs[j].DownVotes
+	})
+
+	return firstN(rs, n)
+}
+
+// CumulativeVoteCurve returns, for every total vote count from 0 up to the highest one, how many images
+// received at least that many votes, as a survival curve. An empty catalog gives an empty curve.
+func (m *ImageManager) CumulativeVoteCurve() []struct{ Threshold, Count int } {
+	images := m.All()
+	if len(images) == 0 {
+		return nil
+	}
+
+	highest := 0
+	counts := make(map[int]int)
+	for _, im := range images {
+		total := im.UpVotes + im.DownVotes
+		counts[total]++
+		if total > highest {
+			highest = total
+		}
+	}
+
+	curve := make([]struct{ Threshold, Count int }, highest+1)
+	atLeast := 0
+	for threshold := highest; threshold >= 0; threshold-- {
+		atLeast += counts[threshold]
+		curve[threshold].Threshold = threshold
+		curve[threshold].Count = atLeast
+	}
+
+	return curve
+}
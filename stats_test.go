@@ -0,0 +1,280 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// newCatalog returns an ImageManager without database holding the images.
+func newCatalog(t *testing.T, images ...*Image) *ImageManager {
+	t.Helper()
+	m := NewImageManager()
+	saveImages(t, m, images...)
+	return m
+}
+
+func TestAverageVotesPerImage(t *testing.T) {
+	if avg := NewImageManager().AverageVotesPerImage(); avg != 0 {
+		t.Errorf("empty catalog average = %v, want 0", avg)
+	}
+
+	m := newCatalog(t, &Image{ID: "1", UpVotes: 3, DownVotes: 1}, &Image{ID: "2", UpVotes: 1}, &Image{ID: "3"})
+	if avg := m.AverageVotesPerImage(); avg != 5.0/3 {
+		t.Errorf("average = %v, want %v", avg, 5.0/3)
+	}
+}
+
+func TestClosestToScore(t *testing.T) {
+	m := newCatalog(t, &Image{ID: "1", UpVotes: 5}, &Image{ID: "2", UpVotes: 2}, &Image{ID: "3", DownVotes: 4})
+
+	if im, ok := m.ClosestToScore(2); !ok || im.ID != "2" {
+		t.Errorf("exact match = %v, want 2", im)
+	}
+	if im, ok := m.ClosestToScore(-2); !ok || im.ID != "3" {
+		t.Errorf("nearest to -2 = %v, want 3", im)
+	}
+	if _, ok := NewImageManager().ClosestToScore(0); ok {
+		t.Error("found an image in an empty catalog")
+	}
+}
+
+func TestDuplicateTitles(t *testing.T) {
+	m := newCatalog(t, &Image{ID: "1", Title: "Rex"}, &Image{ID: "2", Title: " rex "}, &Image{ID: "3", Title: "Fido"},
+		&Image{ID: "4", Title: "Max"}, &Image{ID: "5", Title: "MAX"}, &Image{ID: "6", Title: "REX"})
+
+	groups := m.DuplicateTitles()
+	if len(groups) != 2 {
+		t.Fatalf("groups = %v, want rex and max", groups)
+	}
+	if ids := imageIDs(groups["rex"]); len(ids) != 3 {
+		t.Errorf("rex = %v, want 3 images", ids)
+	}
+	if ids := imageIDs(groups["max"]); len(ids) != 2 {
+		t.Errorf("max = %v, want 2 images", ids)
+	}
+}
+
+func TestRankDelta(t *testing.T) {
+	old := []Image{{ID: "1", UpVotes: 5}, {ID: "2", UpVotes: 3}, {ID: "3", UpVotes: 1}, {ID: "gone", UpVotes: 9}}
+	m := newCatalog(t, &Image{ID: "1", UpVotes: 5}, &Image{ID: "2", UpVotes: 3}, &Image{ID: "3", UpVotes: 8},
+		&Image{ID: "new", UpVotes: 4})
+
+	want := map[string]int{"3": 3, "1": 0, "new": 2, "2": -1}
+	got := m.RankDelta(old)
+	if len(got) != len(want) {
+		t.Fatalf("RankDelta = %v, want %v", got, want)
+	}
+	for id, delta := range want {
+		if got[id] != delta {
+			t.Errorf("delta of %s = %d, want %d", id, got[id], delta)
+		}
+	}
+}
+
+func TestVoteGini(t *testing.T) {
+	even := newCatalog(t, &Image{ID: "1", UpVotes: 2}, &Image{ID: "2", DownVotes: 2})
+	if g := even.VoteGini(); g != 0 {
+		t.Errorf("even distribution = %v, want 0", g)
+	}
+
+	// Totals 0, 0, 0, 4: all the votes on one of four images.
+	concentrated := newCatalog(t, &Image{ID: "1"}, &Image{ID: "2"}, &Image{ID: "3"}, &Image{ID: "4", UpVotes: 3, DownVotes: 1})
+	if g := concentrated.VoteGini(); math.Abs(g-0.75) > 1e-9 {
+		t.Errorf("concentrated distribution = %v, want 0.75", g)
+	}
+
+	// Totals 1, 2, 3: G = 2*(1+4+9)/(3*6) - 4/3 = 2/9.
+	spread := newCatalog(t, &Image{ID: "1", UpVotes: 1}, &Image{ID: "2", UpVotes: 2}, &Image{ID: "3", DownVotes: 3})
+	if g := spread.VoteGini(); math.Abs(g-2.0/9) > 1e-9 {
+		t.Errorf("spread distribution = %v, want %v", g, 2.0/9)
+	}
+
+	if g := newCatalog(t, &Image{ID: "1", UpVotes: 5}).VoteGini(); g != 0 {
+		t.Errorf("single image = %v, want 0", g)
+	}
+}
+
+func TestByScoreRange(t *testing.T) {
+	m := newCatalog(t, &Image{ID: "1", UpVotes: 5}, &Image{ID: "2", UpVotes: 1}, &Image{ID: "3", DownVotes: 2},
+		&Image{ID: "4", UpVotes: 2, DownVotes: 2})
+
+	rs, err := m.ByScoreRange(-2, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := imageIDs(rs); len(ids) != 3 || ids[0] != "2" || ids[1] != "3" || ids[2] != "4" {
+		t.Errorf("ByScoreRange(-2, 1) = %v, want [2 3 4]", ids)
+	}
+
+	if _, err := m.ByScoreRange(3, 1); err == nil {
+		t.Error("inverted range succeeded, want an error")
+	}
+}
+
+func TestVotePercentile(t *testing.T) {
+	if v := NewImageManager().VotePercentile(50); v != 0 {
+		t.Errorf("empty catalog percentile = %d, want 0", v)
+	}
+
+	var images []*Image
+	for i := 1; i <= 10; i++ {
+		images = append(images, &Image{ID: strconv.Itoa(i), UpVotes: i - i/2, DownVotes: i / 2})
+	}
+	m := newCatalog(t, images...)
+
+	tests := []struct {
+		p    float64
+		want int
+	}{
+		{50, 5},
+		{90, 9},
+		{0, 1},
+		{100, 10},
+		{-5, 1},
+		{150, 10},
+	}
+	for _, tt := range tests {
+		if v := m.VotePercentile(tt.p); v != tt.want {
+			t.Errorf("VotePercentile(%v) = %d, want %d", tt.p, v, tt.want)
+		}
+	}
+}
+
+func TestMostDecisive(t *testing.T) {
+	m := newCatalog(t, &Image{ID: "1", UpVotes: 10, DownVotes: 2}, &Image{ID: "2", UpVotes: 1, DownVotes: 9},
+		&Image{ID: "3", UpVotes: 3}, &Image{ID: "4", UpVotes: 5, DownVotes: 5}, &Image{ID: "5", UpVotes: 4})
+
+	if ids := imageIDs(m.MostDecisive(10, 0)); !reflect.DeepEqual(ids, []string{"1", "2", "5", "3", "4"}) {
+		t.Errorf("MostDecisive = %v, want [1 2 5 3 4]", ids)
+	}
+	if ids := imageIDs(m.MostDecisive(10, 5)); !reflect.DeepEqual(ids, []string{"1", "2", "4"}) {
+		t.Errorf("MostDecisive with 5 votes at least = %v, want [1 2 4]", ids)
+	}
+	if ids := imageIDs(m.MostDecisive(1, 5)); !reflect.DeepEqual(ids, []string{"1"}) {
+		t.Errorf("first MostDecisive = %v, want [1]", ids)
+	}
+}
+
+func TestHasQuorum(t *testing.T) {
+	m := newCatalog(t, &Image{ID: "below", UpVotes: 3, DownVotes: 1}, &Image{ID: "at", UpVotes: 1, DownVotes: 4})
+
+	if ok, err := m.HasQuorum("below", 5); err != nil || ok {
+		t.Errorf("HasQuorum just below = %v, %v, want false", ok, err)
+	}
+	if ok, err := m.HasQuorum("at", 5); err != nil || !ok {
+		t.Errorf("HasQuorum at the quorum = %v, %v, want true", ok, err)
+	}
+	if _, err := m.HasQuorum("unknown", 5); err != ErrImageNotFound {
+		t.Errorf("HasQuorum of an unknown image error = %v, want ErrImageNotFound", err)
+	}
+}
+
+func TestLeaderboardQuorum(t *testing.T) {
+	m := newCatalog(t, &Image{ID: "1", UpVotes: 4}, &Image{ID: "2", UpVotes: 3, DownVotes: 2}, &Image{ID: "3", DownVotes: 5})
+
+	if ids := imageIDs(m.Leaderboard(10, 5)); !reflect.DeepEqual(ids, []string{"2", "3"}) {
+		t.Errorf("Leaderboard = %v, want [2 3] without the image below the quorum", ids)
+	}
+	if ids := imageIDs(m.Leaderboard(2, 0)); !reflect.DeepEqual(ids, []string{"1", "2"}) {
+		t.Errorf("Leaderboard without quorum = %v, want [1 2]", ids)
+	}
+}
+
+func TestGroupByInitial(t *testing.T) {
+	m := newCatalog(t, &Image{ID: "1", Title: "rex"}, &Image{ID: "2", Title: "Rover"}, &Image{ID: "3", Title: " max"},
+		&Image{ID: "4", Title: "101 Dalmatians"}, &Image{ID: "5"}, &Image{ID: "6", Title: "élise"})
+
+	groups := m.GroupByInitial()
+	got := make(map[string][]string)
+	for initial, images := range groups {
+		got[initial] = imageIDs(images)
+	}
+
+	want := map[string][]string{"R": {"1", "2"}, "M": {"3"}, "#": {"4", "5"}, "É": {"6"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("GroupByInitial = %v, want %v", got, want)
+	}
+}
+
+func TestClosestPair(t *testing.T) {
+	m := newCatalog(t, &Image{ID: "1", UpVotes: 20}, &Image{ID: "2", UpVotes: 11}, &Image{ID: "3", UpVotes: 9, DownVotes: 1},
+		&Image{ID: "4", UpVotes: 4}, &Image{ID: "5", DownVotes: 3})
+
+	a, b, ok := m.ClosestPair()
+	if !ok || a.ID != "2" || b.ID != "3" {
+		t.Errorf("ClosestPair = %v, %v, %v, want 2 and 3", a, b, ok)
+	}
+
+	if _, _, ok := newCatalog(t, &Image{ID: "1"}).ClosestPair(); ok {
+		t.Error("found a pair among a single image")
+	}
+}
+
+func TestTopByRatio(t *testing.T) {
+	m := newCatalog(t, &Image{ID: "lucky", UpVotes: 1}, &Image{ID: "1", UpVotes: 9, DownVotes: 1},
+		&Image{ID: "2", UpVotes: 8, DownVotes: 2}, &Image{ID: "3", UpVotes: 16, DownVotes: 4}, &Image{ID: "4", DownVotes: 5},
+		&Image{ID: "none"})
+
+	if ids := imageIDs(m.TopByRatio(10, 5)); !reflect.DeepEqual(ids, []string{"1", "3", "2", "4"}) {
+		t.Errorf("TopByRatio = %v, want [1 3 2 4], 3 having more votes than 2", ids)
+	}
+	if ids := imageIDs(m.TopByRatio(2, 0)); !reflect.DeepEqual(ids, []string{"lucky", "1"}) {
+		t.Errorf("TopByRatio without minimum = %v, want [lucky 1]", ids)
+	}
+}
+
+func TestTieBreakerByTitle(t *testing.T) {
+	m := newCatalog(t, &Image{ID: "1", Title: "Rex", UpVotes: 3, DownVotes: 1}, &Image{ID: "2", Title: "Fido", UpVotes: 2},
+		&Image{ID: "3", Title: "Max", UpVotes: 5})
+
+	if ids := imageIDs(m.Leaderboard(3, 0)); !reflect.DeepEqual(ids, []string{"3", "1", "2"}) {
+		t.Errorf("Leaderboard = %v, want the tie broken by up votes by default", ids)
+	}
+
+	m.TieBreaker = func(a, b *Image) bool { return a.Title < b.Title }
+	if ids := imageIDs(m.Leaderboard(3, 0)); !reflect.DeepEqual(ids, []string{"3", "2", "1"}) {
+		t.Errorf("Leaderboard = %v, want the tie broken by title", ids)
+	}
+}
+
+func TestCumulativeVoteCurve(t *testing.T) {
+	if curve := NewImageManager().CumulativeVoteCurve(); len(curve) != 0 {
+		t.Errorf("empty catalog curve = %v, want none", curve)
+	}
+
+	m := newCatalog(t, &Image{ID: "1"}, &Image{ID: "2", UpVotes: 2}, &Image{ID: "3", UpVotes: 1, DownVotes: 1},
+		&Image{ID: "4", DownVotes: 3})
+
+	var counts []int
+	for threshold, point := range m.CumulativeVoteCurve() {
+		if point.Threshold != threshold {
+			t.Errorf("point %d has threshold %d", threshold, point.Threshold)
+		}
+		counts = append(counts, point.Count)
+	}
+	if want := []int{4, 3, 3, 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("counts = %v, want %v", counts, want)
+	}
+}
+
+func TestRankingTiesInIDOrder(t *testing.T) {
+	m := newCatalog(t, &Image{ID: "c", Title: "Rex", UpVotes: 2, Impressions: 4}, &Image{ID: "a", Title: "Rex", UpVotes: 2, Impressions: 4},
+		&Image{ID: "b", Title: "Rex", UpVotes: 2, Impressions: 4})
+	m.TieBreaker = func(a, b *Image) bool { return a.Title < b.Title }
+
+	want := []string{"a", "b", "c"}
+	rankings := map[string][]*Image{
+		"Leaderboard":     m.Leaderboard(3, 0),
+		"MostDecisive":    m.MostDecisive(3, 0),
+		"TopByRatio":      m.TopByRatio(3, 0),
+		"TopByEngagement": m.TopByEngagement(3),
+		"FairOrder":       m.FairOrder(3),
+	}
+	for name, ranked := range rankings {
+		if ids := imageIDs(ranked); !reflect.DeepEqual(ids, want) {
+			t.Errorf("%s = %v, want the ties in id order", name, ids)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/gorilla/mux"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// thumbnailMaxAge is how long, in seconds, clients may cache a proxied thumbnail.
+const thumbnailMaxAge = 24 * 60 * 60
+
+// ThumbnailProxyHandler returns a handler streaming the thumbnail of the puppy with the requested id,
+// fetched from Flickr through client, so clients never see the Flickr URLs.
+func ThumbnailProxyHandler(client *http.Client) http.HandlerFunc {
+	return errorHandler(func(w http.ResponseWriter, r *http.Request) error {
+		id := mux.Vars(r)["id"]
+
+		imageManager, err := openImageManager()
+		if err != nil {
+			return err
+		}
+		defer imageManager.GetDB().Close()
+
+		image, ok := imageManager.Find(id)
+		if !ok {
+			return notFound{errors.New("puppy not found")}
+		}
+
+		resp, err := client.Get(image.Thumbnail)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("fetching thumbnail of %s: %s", id, resp.Status)
+		}
+
+		contentType := resp.Header.Get("Content-Type")
+		if contentType == "" {
+			contentType = "image/jpeg"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", thumbnailMaxAge))
+
+		// The headers are already sent, so a failed copy can only be logged.
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			log.Println(err)
+		}
+		return nil
+	})
+}
+
+// PrefetchThumbnails requests the thumbnail of every image through the HTTPClient, concurrency at a time,
+// e.g. to warm a CDN. It stops at the first failure or when ctx is done, and returns why.
+func (m *ImageManager) PrefetchThumbnails(ctx context.Context, concurrency int) error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	urls := make(chan string)
+	errs := make(chan error, concurrency)
+	var wg sync.WaitGroup
+
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range urls {
+				if err := m.prefetch(ctx, u); err != nil {
+					errs <- err
+					cancel()
+					return
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, im := range m.All() {
+		select {
+		case urls <- im.Thumbnail:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(urls)
+	wg.Wait()
+	close(errs)
+
+	if err := <-errs; err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// prefetch requests the URL and discards the response.
+func (m *ImageManager) prefetch(ctx context.Context, u string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := m.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("prefetching %s: %s", u, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestThumbnailProxyHandler(t *testing.T) {
+	flickr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/rex_t.jpg" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte("jpeg bytes"))
+	}))
+	defer flickr.Close()
+
+	m := newTestManager(t)
+	m.InsertPuppies([]*Image{{ID: "1", Thumbnail: flickr.URL + "/rex_t.jpg"}})
+	handler := ThumbnailProxyHandler(flickr.Client())
+
+	w := httptest.NewRecorder()
+	handler(w, mux.SetURLVars(httptest.NewRequest("GET", ThumbnailPath+"/1", nil), map[string]string{"id": "1"}))
+	if w.Code != http.StatusOK || w.Body.String() != "jpeg bytes" || w.Header().Get("Content-Type") != "image/jpeg" {
+		t.Errorf("got %d %q %q, want the image bytes", w.Code, w.Header().Get("Content-Type"), w.Body)
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, mux.SetURLVars(httptest.NewRequest("GET", ThumbnailPath+"/2", nil), map[string]string{"id": "2"}))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("unknown puppy status = %d, want 404", w.Code)
+	}
+}
+
+func TestPrefetchThumbnails(t *testing.T) {
+	var mu sync.Mutex
+	requested := make(map[string]int)
+	flickr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requested[r.URL.Path]++
+		mu.Unlock()
+		w.Write([]byte("jpeg"))
+	}))
+	defer flickr.Close()
+
+	m := NewImageManager()
+	m.HTTPClient = flickr.Client()
+	for i := 1; i <= 10; i++ {
+		saveImages(t, m, &Image{ID: strconv.Itoa(i), Thumbnail: flickr.URL + "/" + strconv.Itoa(i) + "_t.jpg"})
+	}
+
+	if err := m.PrefetchThumbnails(context.Background(), 3); err != nil {
+		t.Fatal(err)
+	}
+	if len(requested) != 10 {
+		t.Errorf("%d thumbnails requested, want 10", len(requested))
+	}
+	for path, n := range requested {
+		if n != 1 {
+			t.Errorf("%s requested %d times, want once", path, n)
+		}
+	}
+}
+
+func TestPrefetchThumbnailsCanceled(t *testing.T) {
+	m := NewImageManager()
+	m.HTTPClient = stubClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("%s requested with a canceled context", r.URL)
+	}))
+	saveImages(t, m, &Image{ID: "1", Thumbnail: "https://example.com/1_t.jpg"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := m.PrefetchThumbnails(ctx, 2); !errors.Is(err, context.Canceled) {
+		t.Errorf("PrefetchThumbnails error = %v, want context.Canceled", err)
+	}
+}
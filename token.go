@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VoteTokenTTL is how long a vote token issued with a listing stays valid.
+const VoteTokenTTL = time.Hour
+
+// ErrInvalidVoteToken is returned for vote tokens which are missing, expired, were not issued by the server
+// or were issued for listings without the voted image.
+var ErrInvalidVoteToken = errors.New("invalid vote token")
+
+// voteTokenKey signs the vote tokens. It is generated at start, so restarting the server invalidates them.
+var voteTokenKey = newVoteTokenKey()
+
+func newVoteTokenKey() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(err)
+	}
+	return key
+}
+
+func voteTokenMAC(payload string) string {
+	mac := hmac.New(sha256.New, voteTokenKey)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// IssueVoteToken returns a token allowing votes on the images with the given ids, those of the listing it is
+// issued with, until VoteTokenTTL after now. The token carries the expiry and the ids, signed together.
+func IssueVoteToken(ids []string, now time.Time) string {
+	payload := strconv.FormatInt(now.Add(VoteTokenTTL).Unix(), 10) + "." + strings.Join(ids, ",")
+	return payload + "." + voteTokenMAC(payload)
+}
+
+// VerifyVoteToken returns ErrInvalidVoteToken unless the token was issued by IssueVoteToken for a listing of
+// the image with the given id, and is still valid at now.
+func VerifyVoteToken(token, id string, now time.Time) error {
+	i := strings.LastIndex(token, ".")
+	if i < 0 || !hmac.Equal([]byte(token[i+1:]), []byte(voteTokenMAC(token[:i]))) {
+		return ErrInvalidVoteToken
+	}
+
+	parts := strings.SplitN(token[:i], ".", 2)
+	if len(parts) != 2 {
+		return ErrInvalidVoteToken
+	}
+
+	expiry, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || now.Unix() > expiry {
+		return ErrInvalidVoteToken
+	}
+
+	for _, listed := range strings.Split(parts[1], ",") {
+		if listed == id {
+			return nil
+		}
+	}
+	return ErrInvalidVoteToken
+}
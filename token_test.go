@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVoteToken(t *testing.T) {
+	now := time.Now()
+	token := IssueVoteToken([]string{"1", "22"}, now)
+
+	tests := []struct {
+		name, token, id string
+		at              time.Time
+		valid           bool
+	}{
+		{"valid", token, "22", now, true},
+		{"valid until expiry", token, "1", now.Add(VoteTokenTTL), true},
+		{"expired", token, "1", now.Add(VoteTokenTTL + time.Second), false},
+		{"unlisted id", token, "2", now, false},
+		{"tampered ids", strings.Replace(token, ".1,", ".2,", 1), "2", now, false},
+		{"tampered expiry", "9" + token, "1", now, false},
+		{"tampered mac", token[:len(token)-1] + "x", "1", now, false},
+		{"missing", "", "1", now, false},
+	}
+
+	for _, tt := range tests {
+		err := VerifyVoteToken(tt.token, tt.id, tt.at)
+		if tt.valid && err != nil {
+			t.Errorf("%s: error = %v, want nil", tt.name, err)
+		}
+		if !tt.valid && err != ErrInvalidVoteToken {
+			t.Errorf("%s: error = %v, want ErrInvalidVoteToken", tt.name, err)
+		}
+	}
+}
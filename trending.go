@@ -0,0 +1,185 @@
+package main
+
+import (
+	"log"
+	"math"
+	"sort"
+	"time"
+)
+
+// TrendingGravity is how fast the trending score of an image decays as it ages.
+const TrendingGravity = 1.8
+
+// trendingScore computes the trending score of an image, in the manner of Hacker News:
+//
+//	(score + velocity) / (age + 2) ^ TrendingGravity
+//
+// where score is the net score, velocity the net votes per hour received within the window,
+// and age the hours since the first vote of the image (0 when it has none).
+func trendingScore(image *Image, change int, firstVote int64, window time.Duration, now time.Time) float64 {
+	velocity := 0.0
+	if hours := window.Hours(); hours > 0 {
+		velocity = float64(change) / hours
+	}
+
+	age := 0.0
+	if firstVote > 0 {
+		age = now.Sub(time.Unix(firstVote, 0)).Hours()
+	}
+
+	return (float64(image.Score()) + velocity) / math.Pow(age+2, TrendingGravity)
+}
+
+// TrendingScore returns the trending score of the image over the window; see trendingScore for the formula.
+// It returns ErrImageNotFound for an unknown id.
+func (m *ImageManager) TrendingScore(id string, window time.Duration) (float64, error) {
+	image, ok := m.Find(id)
+	if !ok {
+		return 0, ErrImageNotFound
+	}
+
+	scores, err := m.trendingScores(window)
+	if err != nil {
+		return 0, err
+	}
+
+	return scores[image.ID], nil
+}
+
+// TopTrending returns the n images with the highest trending score over the window.
+func (m *ImageManager) TopTrending(n int, window time.Duration) []*Image {
+	scores, err := m.trendingScores(window)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	rs := m.sortByScore(m.All())
+	sort.SliceStable(rs, func(i, j int) bool {
+		return scores[rs[i].ID] > scores[rs[j].ID]
+	})
+
+	return firstN(rs, n)
+}
+
+// trendingScores returns the trending score over the window of every image, keyed by id.
+func (m *ImageManager) trendingScores(window time.Duration) (map[string]float64, error) {
+	now := m.now()
+
+	changes, err := m.scoreChangesSince(now.Add(-window))
+	if err != nil {
+		return nil, err
+	}
+
+	firstVotes, err := m.voteTimes("min")
+	if err != nil {
+		return nil, err
+	}
+
+	scores := make(map[string]float64)
+	for _, im := range m.All() {
+		scores[im.ID] = trendingScore(im, changes[im.ID], firstVotes[im.ID], window, now)
+	}
+
+	return scores, nil
+}
+
+// TopByRecency returns the n images with the highest time-decayed net score, every logged vote counting for
+// half as much each halfLife since it was cast, so that the recently popular images rise.
+func (m *ImageManager) TopByRecency(n int, halfLife time.Duration) []*Image {
+	scores, err := m.decayedScores(halfLife)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	rs := m.sortByScore(m.All())
+	sort.SliceStable(rs, func(i, j int) bool {
+		return scores[rs[i].ID] > scores[rs[j].ID]
+	})
+
+	return firstN(rs, n)
+}
+
+// decayedScores returns, per puppy id, the net score of its logged votes weighted by 0.5 ^ (age / halfLife).
+// A halfLife of zero or less doesn't decay the votes.
+func (m *ImageManager) decayedScores(halfLife time.Duration) (map[string]float64, error) {
+	rows, err := m.query("select puppy_id, up_vote, created_at from vote_log")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := m.now()
+	scores := make(map[string]float64)
+	for rows.Next() {
+		var id string
+		var up bool
+		var createdAt int64
+		if err := rows.Scan(&id, &up, &createdAt); err != nil {
+			return nil, err
+		}
+
+		weight := 1.0
+		if halfLife > 0 {
+			weight = math.Pow(0.5, now.Sub(time.Unix(createdAt, 0)).Seconds()/halfLife.Seconds())
+		}
+		if !up {
+			weight = -weight
+		}
+		scores[id] += weight
+	}
+
+	return scores, rows.Err()
+}
+
+// Momentum returns how much the vote velocity of the image, in net votes per hour, rose in the last window
+// compared to the window before it. It is positive when the image is accelerating and negative when it slows down.
+// It returns ErrImageNotFound for an unknown id.
+func (m *ImageManager) Momentum(id string, window time.Duration) (float64, error) {
+	recent, prior, err := m.windowChanges(id, window)
+	if err != nil || window <= 0 {
+		return 0, err
+	}
+
+	return float64(recent-prior) / window.Hours(), nil
+}
+
+// TrendDirection returns 1 when the image gained more net votes in the last window than in the window before it,
+// -1 when it gained fewer and 0 when as many, e.g. to pick a trend arrow. It returns ErrImageNotFound for an unknown id.
+func (m *ImageManager) TrendDirection(id string, window time.Duration) (int, error) {
+	recent, prior, err := m.windowChanges(id, window)
+	if err != nil {
+		return 0, err
+	}
+
+	switch {
+	case recent > prior:
+		return 1, nil
+	case recent < prior:
+		return -1, nil
+	default:
+		return 0, nil
+	}
+}
+
+// windowChanges returns the change of net score of the image caused by the votes logged in the last window,
+// and by the ones logged in the window before it; both are 0 for an empty window.
+// It returns ErrImageNotFound for an unknown id.
+func (m *ImageManager) windowChanges(id string, window time.Duration) (recent, prior int, err error) {
+	image, ok := m.Find(id)
+	if !ok {
+		return 0, 0, ErrImageNotFound
+	}
+	if window <= 0 {
+		return 0, 0, nil
+	}
+
+	now := m.now()
+	err = m.queryRow(`select
+		coalesce(sum(case when created_at >= ? then (case when up_vote then 1 else -1 end) else 0 end), 0),
+		coalesce(sum(case when created_at < ? then (case when up_vote then 1 else -1 end) else 0 end), 0)
+		from vote_log where puppy_id = ? and created_at >= ? and created_at < ?`,
+		now.Add(-window).Unix(), now.Add(-window).Unix(), image.ID, now.Add(-2*window).Unix(), now.Unix()+1).Scan(&recent, &prior)
+	return recent, prior, err
+}
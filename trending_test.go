@@ -0,0 +1,121 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTrendingScore(t *testing.T) {
+	m := newTestManager(t)
+	now := time.Now()
+	fixedClock(m, now)
+	saveImages(t, m, &Image{ID: "1", UpVotes: 50}, &Image{ID: "2", UpVotes: 6})
+
+	for i := 0; i < 50; i++ {
+		logVoteAt(t, m, now.Add(-100*time.Hour), 1, true, "a")
+	}
+	for i := 0; i < 6; i++ {
+		logVoteAt(t, m, now.Add(-time.Hour+time.Duration(i)*time.Minute), 2, true, "a")
+	}
+
+	rising, err := m.TrendingScore("2", 2*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	established, err := m.TrendingScore("1", 2*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rising <= established {
+		t.Errorf("fast-rising score %v <= old high score %v", rising, established)
+	}
+
+	if ids := imageIDs(m.TopTrending(2, 2*time.Hour)); !reflect.DeepEqual(ids, []string{"2", "1"}) {
+		t.Errorf("TopTrending = %v, want [2 1]", ids)
+	}
+	if _, err := m.TrendingScore("3", time.Hour); err != ErrImageNotFound {
+		t.Errorf("unknown image error = %v, want ErrImageNotFound", err)
+	}
+}
+
+func TestTopByRecency(t *testing.T) {
+	m := newTestManager(t)
+	now := time.Now()
+	fixedClock(m, now)
+	saveImages(t, m, &Image{ID: "1", UpVotes: 10}, &Image{ID: "2", UpVotes: 3})
+
+	for i := 0; i < 10; i++ {
+		logVoteAt(t, m, now.Add(-10*24*time.Hour), 1, true, "a")
+	}
+	for i := 0; i < 3; i++ {
+		logVoteAt(t, m, now.Add(-time.Hour), 2, true, "a")
+	}
+
+	if ids := imageIDs(m.TopByRecency(2, 24*time.Hour)); !reflect.DeepEqual(ids, []string{"2", "1"}) {
+		t.Errorf("TopByRecency = %v, want the recently voted image first", ids)
+	}
+	if ids := imageIDs(m.TopByRecency(2, 0)); !reflect.DeepEqual(ids, []string{"1", "2"}) {
+		t.Errorf("TopByRecency without decay = %v, want the raw scores order", ids)
+	}
+}
+
+// logPattern logs prior up votes on the image cast in the hour before the last one, and recent ones in the last hour.
+func logPattern(t *testing.T, m *ImageManager, now time.Time, id, prior, recent int) {
+	t.Helper()
+	for i := 0; i < prior; i++ {
+		logVoteAt(t, m, now.Add(-90*time.Minute), id, true, "a")
+	}
+	for i := 0; i < recent; i++ {
+		logVoteAt(t, m, now.Add(-30*time.Minute), id, true, "a")
+	}
+}
+
+func TestMomentum(t *testing.T) {
+	m := newTestManager(t)
+	now := time.Now()
+	fixedClock(m, now)
+	saveImages(t, m, &Image{ID: "1"}, &Image{ID: "2"}, &Image{ID: "3"})
+	logPattern(t, m, now, 1, 1, 5)
+	logPattern(t, m, now, 2, 4, 1)
+	logVoteAt(t, m, now.Add(-3*time.Hour), 3, true, "a")
+
+	tests := []struct {
+		id   string
+		want float64
+	}{
+		{"1", 4},
+		{"2", -3},
+		{"3", 0},
+	}
+	for _, tt := range tests {
+		if got, err := m.Momentum(tt.id, time.Hour); err != nil || got != tt.want {
+			t.Errorf("Momentum(%s) = %v, %v, want %v", tt.id, got, err, tt.want)
+		}
+	}
+	if got, err := m.Momentum("1", 2*time.Hour); err != nil || got != 3 {
+		t.Errorf("Momentum over 2 hours = %v, %v, want 6 votes in 2 hours against none", got, err)
+	}
+	if _, err := m.Momentum("4", time.Hour); err != ErrImageNotFound {
+		t.Errorf("Momentum of an unknown image error = %v, want ErrImageNotFound", err)
+	}
+}
+
+func TestTrendDirection(t *testing.T) {
+	m := newTestManager(t)
+	now := time.Now()
+	fixedClock(m, now)
+	saveImages(t, m, &Image{ID: "1"}, &Image{ID: "2"}, &Image{ID: "3"})
+	logPattern(t, m, now, 1, 1, 3)
+	logPattern(t, m, now, 2, 3, 1)
+	logPattern(t, m, now, 3, 2, 2)
+
+	for id, want := range map[string]int{"1": 1, "2": -1, "3": 0} {
+		if got, err := m.TrendDirection(id, time.Hour); err != nil || got != want {
+			t.Errorf("TrendDirection(%s) = %d, %v, want %d", id, got, err, want)
+		}
+	}
+	if _, err := m.TrendDirection("4", time.Hour); err != ErrImageNotFound {
+		t.Errorf("TrendDirection of an unknown image error = %v, want ErrImageNotFound", err)
+	}
+}
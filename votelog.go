@@ -0,0 +1,397 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"sort"
+	"time"
+)
+
+// secondsPerDay is the length of the day buckets used to aggregate the vote_log.
+const secondsPerDay = 24 * 60 * 60
+
+// DaySentiment is the share of up votes among the votes cast on a single (UTC) day.
+// Ratio is nil for days without any vote.
+type DaySentiment struct {
+	Day   time.Time     `json:"day"`
+	Up    int           `json:"up"`
+	Down  int           `json:"down"`
+	Ratio *RoundedScore `json:"ratio"`
+}
+
+// logVote records a vote cast by voter on the given puppy in the vote_log.
+func (m *ImageManager) logVote(puppy_id int, up_vote bool, voter string) error {
+	_, err := m.exec("insert into vote_log(puppy_id, up_vote, voter_id, created_at) values(?, ?, ?, ?)",
+		puppy_id, up_vote, voter, m.now().Unix())
+	return err
+}
+
+// unlogVote removes from the vote_log the latest vote of the given kind on the puppy, preferably one cast by voter.
+func (m *ImageManager) unlogVote(puppy_id int, up_vote bool, voter string) error {
+	_, err := m.exec(`delete from vote_log where id = (select id from vote_log where puppy_id = ? and up_vote = ?
+		order by voter_id = ? desc, id desc limit 1)`, puppy_id, up_vote, voter)
+	return err
+}
+
+// VoterActivity returns how many up and down votes the given voter cast, according to the vote_log.
+func (m *ImageManager) VoterActivity(voterID string) (up, down int, err error) {
+	err = m.queryRow("select coalesce(sum(up_vote), 0), coalesce(sum(not up_vote), 0) from vote_log where voter_id = ?",
+		voterID).Scan(&up, &down)
+	return up, down, err
+}
+
+// SentimentTrend returns the up/down share of the votes cast on each of the last days days, oldest first.
+// Days without votes are included with a nil ratio.
+func (m *ImageManager) SentimentTrend(days int) ([]DaySentiment, error) {
+	if days <= 0 {
+		return nil, errors.New("days must be positive")
+	}
+
+	totals, err := m.VoteTotals(secondsPerDay*time.Second, days)
+	if err != nil {
+		return nil, err
+	}
+
+	trend := make([]DaySentiment, days)
+	for i, t := range totals {
+		trend[i] = DaySentiment{Day: t.Start, Up: t.Up, Down: t.Down}
+		if total := t.Up + t.Down; total > 0 {
+			ratio := RoundedScore(t.Up) / RoundedScore(total)
+			trend[i].Ratio = &ratio
+		}
+	}
+
+	return trend, nil
+}
+
+// VoteBucket is the votes cast within a time bucket starting at Start.
+type VoteBucket struct {
+	Start time.Time `json:"start"`
+	Up    int       `json:"up"`
+	Down  int       `json:"down"`
+}
+
+// VoteTotals returns the up and down votes cast within each of the last n buckets, e.g. hours, days or weeks,
+// oldest first and including the current one. Buckets are aligned on the unix epoch, hence days on UTC midnight.
+func (m *ImageManager) VoteTotals(bucket time.Duration, n int) ([]VoteBucket, error) {
+	if bucket < time.Second {
+		return nil, errors.New("bucket must be at least a second")
+	}
+	if n <= 0 {
+		return nil, errors.New("n must be positive")
+	}
+
+	size := int64(bucket / time.Second)
+	current := m.now().Unix() / size
+	first := current - int64(n) + 1
+
+	totals := make([]VoteBucket, n)
+	for i := range totals {
+		totals[i].Start = time.Unix((first+int64(i))*size, 0).UTC()
+	}
+
+	rows, err := m.query(`select created_at / ? as bucket, sum(up_vote), sum(not up_vote) from vote_log
+		where created_at >= ? group by bucket`, size, first*size)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b int64
+		var up, down int
+		if err := rows.Scan(&b, &up, &down); err != nil {
+			return nil, err
+		}
+		if b < first || b > current {
+			continue
+		}
+		totals[b-first].Up = up
+		totals[b-first].Down = down
+	}
+
+	return totals, rows.Err()
+}
+
+// scoreChangesSince returns, per puppy id, the change of net score caused by the votes logged since t.
+func (m *ImageManager) scoreChangesSince(t time.Time) (map[string]int, error) {
+	rows, err := m.query(`select puppy_id, sum(case when up_vote then 1 else -1 end) from vote_log
+		where created_at >= ? group by puppy_id`, t.Unix())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := make(map[string]int)
+	for rows.Next() {
+		var id string
+		var change int
+		if err := rows.Scan(&id, &change); err != nil {
+			return nil, err
+		}
+		changes[id] = change
+	}
+
+	return changes, rows.Err()
+}
+
+// RecentlyPositive returns the images whose net score went from zero or less to positive within the last d,
+// reconstructing their score at the start of the window from the vote_log.
+func (m *ImageManager) RecentlyPositive(d time.Duration) []*Image {
+	changes, err := m.scoreChangesSince(m.now().Add(-d))
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	var rs []*Image
+	for _, im := range m.All() {
+		change, ok := changes[im.ID]
+		if ok && im.Score() > 0 && im.Score()-change <= 0 {
+			rs = append(rs, im)
+		}
+	}
+
+	return rs
+}
+
+// VoteDetail is the full vote record of an image. FirstVote and LastVote are zero when it has no logged vote.
+type VoteDetail struct {
+	ID        string    `json:"id"`
+	UpVotes   int       `json:"upvotes"`
+	DownVotes int       `json:"downvotes"`
+	FirstVote time.Time `json:"first_vote"`
+	LastVote  time.Time `json:"last_vote"`
+	Events    int       `json:"events"`
+}
+
+// VoteDetail returns the current vote counts of the image along with the history summarized from the vote_log.
+// It returns ErrImageNotFound for an unknown id.
+func (m *ImageManager) VoteDetail(id string) (*VoteDetail, error) {
+	image, ok := m.Find(id)
+	if !ok {
+		return nil, ErrImageNotFound
+	}
+
+	detail := &VoteDetail{ID: image.ID, UpVotes: image.UpVotes, DownVotes: image.DownVotes}
+
+	var first, last sql.NullInt64
+	err := m.queryRow("select min(created_at), max(created_at), count(*) from vote_log where puppy_id = ?",
+		id).Scan(&first, &last, &detail.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	if first.Valid {
+		detail.FirstVote = time.Unix(first.Int64, 0)
+		detail.LastVote = time.Unix(last.Int64, 0)
+	}
+
+	return detail, nil
+}
+
+// VoteEvent is a single vote recorded in the vote_log.
+type VoteEvent struct {
+	ID    string    `json:"id"`
+	Up    bool      `json:"up"`
+	Voter string    `json:"voter"`
+	Time  time.Time `json:"time"`
+}
+
+// VoteHistory returns all the votes logged for the image, oldest first.
+func (m *ImageManager) VoteHistory(id string) ([]VoteEvent, error) {
+	return m.voteEvents(id, -1, 0)
+}
+
+// VoteHistoryPage returns at most limit of the votes logged for the image, oldest first, skipping the first offset.
+func (m *ImageManager) VoteHistoryPage(id string, limit, offset int) ([]VoteEvent, error) {
+	if limit <= 0 || offset < 0 {
+		return nil, errors.New("limit must be positive and offset not negative")
+	}
+	return m.voteEvents(id, limit, offset)
+}
+
+// voteEvents pages through the votes logged for the image; a negative limit returns all of them.
+func (m *ImageManager) voteEvents(id string, limit, offset int) ([]VoteEvent, error) {
+	rows, err := m.query(`select puppy_id, up_vote, coalesce(voter_id, ''), created_at from vote_log
+		where puppy_id = ? order by created_at, id limit ? offset ?`, id, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []VoteEvent
+	for rows.Next() {
+		var event VoteEvent
+		var createdAt int64
+		if err := rows.Scan(&event.ID, &event.Up, &event.Voter, &createdAt); err != nil {
+			return nil, err
+		}
+		event.Time = time.Unix(createdAt, 0)
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// MostImproved returns the n images whose net score gained the most within the last d, according to the vote_log.
+// Images without recent votes have a gain of 0.
+func (m *ImageManager) MostImproved(d time.Duration, n int) []*Image {
+	changes, err := m.scoreChangesSince(m.now().Add(-d))
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	rs := m.sortByScore(m.All())
+	sort.SliceStable(rs, func(i, j int) bool {
+		return changes[rs[i].ID] > changes[rs[j].ID]
+	})
+
+	return firstN(rs, n)
+}
+
+// voteTimes returns, per puppy id, the time of its first (agg "min") or last (agg "max") vote in the vote_log.
+func (m *ImageManager) voteTimes(agg string) (map[string]int64, error) {
+	rows, err := m.query("select puppy_id, " + agg + "(created_at) from vote_log group by puppy_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	times := make(map[string]int64)
+	for rows.Next() {
+		var id string
+		var last int64
+		if err := rows.Scan(&id, &last); err != nil {
+			return nil, err
+		}
+		times[id] = last
+	}
+
+	return times, rows.Err()
+}
+
+// RecentlyActive returns the n images voted on most recently, latest first. Images never voted on are left out.
+func (m *ImageManager) RecentlyActive(n int) []*Image {
+	times, err := m.voteTimes("max")
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	var rs []*Image
+	for _, im := range m.sortByScore(m.All()) {
+		if _, ok := times[im.ID]; ok {
+			rs = append(rs, im)
+		}
+	}
+
+	sort.SliceStable(rs, func(i, j int) bool {
+		return times[rs[i].ID] > times[rs[j].ID]
+	})
+
+	return firstN(rs, n)
+}
+
+// FastestToScore returns the image whose net score reached score the soonest after its first vote,
+// along with how long it took, replaying the vote_log. It returns false when no image ever reached the score.
+func (m *ImageManager) FastestToScore(score int) (*Image, time.Duration, bool) {
+	rows, err := m.query("select puppy_id, up_vote, created_at from vote_log order by puppy_id, id")
+	if err != nil {
+		log.Println(err)
+		return nil, 0, false
+	}
+	defer rows.Close()
+
+	known := make(map[string]bool)
+	for _, im := range m.All() {
+		known[im.ID] = true
+	}
+
+	var fastest string
+	var best time.Duration
+	found := false
+
+	var current string
+	var first int64
+	net := 0
+	reached := false
+	for rows.Next() {
+		var id string
+		var up bool
+		var createdAt int64
+		if err := rows.Scan(&id, &up, &createdAt); err != nil {
+			log.Println(err)
+			return nil, 0, false
+		}
+
+		if id != current {
+			current, first, net, reached = id, createdAt, 0, false
+		}
+		if reached || !known[id] {
+			continue
+		}
+
+		if up {
+			net++
+		} else {
+			net--
+		}
+		if net == score {
+			reached = true
+			if d := time.Duration(createdAt-first) * time.Second; !found || d < best {
+				fastest, best, found = id, d, true
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, 0, false
+	}
+
+	if !found {
+		return nil, 0, false
+	}
+	image, ok := m.Find(fastest)
+	if !ok {
+		return nil, 0, false
+	}
+	return image, best, true
+}
+
+// DetectSpikes returns the images which received more than threshold votes within the last window,
+// according to the vote_log, as a sign of ballot stuffing in progress.
+func (m *ImageManager) DetectSpikes(window time.Duration, threshold int) []*Image {
+	rows, err := m.query("select puppy_id from vote_log where created_at >= ? group by puppy_id having count(*) > ?",
+		m.now().Add(-window).Unix(), threshold)
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	defer rows.Close()
+
+	spiked := make(map[string]bool)
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			log.Println(err)
+			return nil
+		}
+		spiked[id] = true
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil
+	}
+
+	var rs []*Image
+	for _, im := range m.All() {
+		if spiked[im.ID] {
+			rs = append(rs, im)
+		}
+	}
+
+	return rs
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// logVoteAt records in the vote_log a vote of voter on the puppy as cast at the given time.
+func logVoteAt(t *testing.T, m *ImageManager, at time.Time, id int, up bool, voter string) {
+	t.Helper()
+	if _, err := m.exec("insert into vote_log(puppy_id, up_vote, voter_id, created_at) values(?, ?, ?, ?)",
+		id, up, voter, at.Unix()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fixedClock makes the clock of m stopped at now.
+func fixedClock(m *ImageManager, now time.Time) {
+	m.Clock = func() time.Time { return now }
+}
+
+func TestSentimentTrend(t *testing.T) {
+	m := newTestManager(t)
+	now := time.Date(2024, 5, 3, 15, 0, 0, 0, time.UTC)
+	fixedClock(m, now)
+
+	logVoteAt(t, m, now.Add(-50*time.Hour), 1, true, "a")
+	logVoteAt(t, m, now.Add(-49*time.Hour), 1, false, "b")
+	logVoteAt(t, m, now.Add(-2*time.Hour), 1, true, "a")
+	logVoteAt(t, m, now.Add(-time.Hour), 2, true, "b")
+	logVoteAt(t, m, now.Add(-time.Hour), 2, false, "c")
+	logVoteAt(t, m, now.Add(-time.Minute), 2, true, "c")
+
+	trend, err := m.SentimentTrend(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trend) != 3 {
+		t.Fatalf("got %d days, want 3", len(trend))
+	}
+
+	if day := trend[0]; !day.Day.Equal(time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)) || day.Up != 1 || day.Down != 1 ||
+		day.Ratio == nil || *day.Ratio != 0.5 {
+		t.Errorf("first day = %+v, want 1 up and 1 down", day)
+	}
+	if day := trend[1]; day.Up != 0 || day.Down != 0 || day.Ratio != nil {
+		t.Errorf("day without votes = %+v, want a nil ratio", day)
+	}
+	if day := trend[2]; day.Up != 3 || day.Down != 1 || day.Ratio == nil || *day.Ratio != 0.75 {
+		t.Errorf("today = %+v, want 3 up and 1 down", day)
+	}
+
+	if _, err := m.SentimentTrend(0); err == nil {
+		t.Error("SentimentTrend(0) succeeded, want an error")
+	}
+}
+
+func TestVoterActivity(t *testing.T) {
+	m := newTestManager(t)
+	now := time.Now()
+	logVoteAt(t, m, now, 1, true, "alice")
+	logVoteAt(t, m, now, 2, true, "alice")
+	logVoteAt(t, m, now, 2, false, "alice")
+	logVoteAt(t, m, now, 1, false, "bob")
+
+	if up, down, err := m.VoterActivity("alice"); err != nil || up != 2 || down != 1 {
+		t.Errorf("alice = %d up, %d down, %v, want 2 up, 1 down", up, down, err)
+	}
+	if up, down, err := m.VoterActivity("carol"); err != nil || up != 0 || down != 0 {
+		t.Errorf("unknown voter = %d up, %d down, %v, want none", up, down, err)
+	}
+}
+
+func TestRecentlyPositive(t *testing.T) {
+	m := newTestManager(t)
+	now := time.Now()
+	fixedClock(m, now)
+	saveImages(t, m, &Image{ID: "1", UpVotes: 3, DownVotes: 2}, &Image{ID: "2", UpVotes: 4, DownVotes: 1})
+
+	// 1 went from -1 to +1 within the hour, while 2 was already positive before it.
+	logVoteAt(t, m, now.Add(-2*time.Hour), 1, false, "a")
+	logVoteAt(t, m, now.Add(-30*time.Minute), 1, true, "a")
+	logVoteAt(t, m, now.Add(-20*time.Minute), 1, true, "b")
+	logVoteAt(t, m, now.Add(-10*time.Minute), 2, true, "a")
+
+	if ids := imageIDs(m.RecentlyPositive(time.Hour)); len(ids) != 1 || ids[0] != "1" {
+		t.Errorf("RecentlyPositive = %v, want [1]", ids)
+	}
+}
+
+func TestVoteDetail(t *testing.T) {
+	m := newTestManager(t)
+	saveImages(t, m, &Image{ID: "1"}, &Image{ID: "2"})
+	m.InsertPuppies(m.All())
+
+	first := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for i, up := range []bool{true, false, true} {
+		fixedClock(m, first.Add(time.Duration(i)*time.Hour))
+		if _, _, err := m.PersistVote("1", up); err != nil {
+			t.Fatal(err)
+		}
+		m.logVote(1, up, "alice")
+	}
+
+	detail, err := m.VoteDetail("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := VoteDetail{ID: "1", UpVotes: 2, DownVotes: 1, FirstVote: first, LastVote: first.Add(2 * time.Hour), Events: 3}
+	if detail.ID != want.ID || detail.UpVotes != want.UpVotes || detail.DownVotes != want.DownVotes ||
+		!detail.FirstVote.Equal(want.FirstVote) || !detail.LastVote.Equal(want.LastVote) || detail.Events != want.Events {
+		t.Errorf("detail = %+v, want %+v", *detail, want)
+	}
+
+	if detail, err := m.VoteDetail("2"); err != nil || !detail.FirstVote.IsZero() || detail.Events != 0 {
+		t.Errorf("detail without votes = %+v, %v, want zero times", detail, err)
+	}
+	if _, err := m.VoteDetail("3"); err != ErrImageNotFound {
+		t.Errorf("unknown image error = %v, want ErrImageNotFound", err)
+	}
+}
+
+func TestVoteHistoryPage(t *testing.T) {
+	m := newTestManager(t)
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		logVoteAt(t, m, start.Add(time.Duration(i)*time.Minute), 1, i%2 == 0, "voter"+strconv.Itoa(i))
+	}
+	logVoteAt(t, m, start, 2, true, "other")
+
+	var voters []string
+	for offset := 0; ; offset += 2 {
+		page, err := m.VoteHistoryPage("1", 2, offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(page) == 0 {
+			break
+		}
+		if len(page) > 2 {
+			t.Fatalf("page at %d has %d events, want at most 2", offset, len(page))
+		}
+		for _, e := range page {
+			voters = append(voters, e.Voter)
+		}
+	}
+
+	want := []string{"voter0", "voter1", "voter2", "voter3", "voter4"}
+	if !reflect.DeepEqual(voters, want) {
+		t.Errorf("paged voters = %v, want %v", voters, want)
+	}
+
+	history, err := m.VoteHistory("1")
+	if err != nil || len(history) != 5 || !history[4].Time.Equal(start.Add(4*time.Minute)) || history[4].Up != true {
+		t.Errorf("history = %v, %v, want the 5 events", history, err)
+	}
+
+	if _, err := m.VoteHistoryPage("1", 0, 0); err == nil {
+		t.Error("VoteHistoryPage with a zero limit succeeded, want an error")
+	}
+}
+
+func TestMostImproved(t *testing.T) {
+	m := newTestManager(t)
+	now := time.Now()
+	fixedClock(m, now)
+	saveImages(t, m, &Image{ID: "1", UpVotes: 9}, &Image{ID: "2", UpVotes: 3}, &Image{ID: "3", UpVotes: 2})
+
+	// Within the hour, 2 gains 3, 3 gains 1 and 1 loses 1; the older up votes of 1 don't count.
+	for i := 0; i < 5; i++ {
+		logVoteAt(t, m, now.Add(-5*time.Hour), 1, true, "a")
+	}
+	logVoteAt(t, m, now.Add(-10*time.Minute), 1, false, "a")
+	for i := 0; i < 3; i++ {
+		logVoteAt(t, m, now.Add(-20*time.Minute), 2, true, "a")
+	}
+	logVoteAt(t, m, now.Add(-30*time.Minute), 3, true, "a")
+
+	if ids := imageIDs(m.MostImproved(time.Hour, 3)); !reflect.DeepEqual(ids, []string{"2", "3", "1"}) {
+		t.Errorf("MostImproved = %v, want [2 3 1]", ids)
+	}
+	if ids := imageIDs(m.MostImproved(time.Hour, 1)); !reflect.DeepEqual(ids, []string{"2"}) {
+		t.Errorf("MostImproved(1) = %v, want [2]", ids)
+	}
+}
+
+func TestRecentlyActive(t *testing.T) {
+	m := newTestManager(t)
+	now := time.Now()
+	saveImages(t, m, &Image{ID: "1"}, &Image{ID: "2"}, &Image{ID: "3"}, &Image{ID: "4"})
+
+	logVoteAt(t, m, now.Add(-3*time.Hour), 1, true, "a")
+	logVoteAt(t, m, now.Add(-time.Minute), 1, false, "a")
+	logVoteAt(t, m, now.Add(-2*time.Hour), 2, true, "a")
+	logVoteAt(t, m, now.Add(-time.Hour), 3, true, "a")
+
+	if ids := imageIDs(m.RecentlyActive(10)); !reflect.DeepEqual(ids, []string{"1", "3", "2"}) {
+		t.Errorf("RecentlyActive = %v, want [1 3 2]", ids)
+	}
+}
+
+func TestVoteTotals(t *testing.T) {
+	m := newTestManager(t)
+	now := time.Date(2024, 5, 3, 10, 30, 0, 0, time.UTC)
+	fixedClock(m, now)
+	logVoteAt(t, m, now.Add(-10*time.Minute), 1, true, "a")
+	logVoteAt(t, m, now.Add(-20*time.Minute), 1, false, "b")
+	logVoteAt(t, m, now.Add(-time.Hour), 2, true, "a")
+	logVoteAt(t, m, now.Add(-6*time.Hour), 2, true, "b")
+	logVoteAt(t, m, now.Add(-26*time.Hour), 1, false, "a")
+	logVoteAt(t, m, now.Add(-5*24*time.Hour), 1, true, "a")
+
+	hourly, err := m.VoteTotals(time.Hour, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []VoteBucket{
+		{Start: time.Date(2024, 5, 3, 8, 0, 0, 0, time.UTC)},
+		{Start: time.Date(2024, 5, 3, 9, 0, 0, 0, time.UTC), Up: 1},
+		{Start: time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC), Up: 1, Down: 1},
+	}
+	if !reflect.DeepEqual(hourly, want) {
+		t.Errorf("hourly totals = %+v, want %+v", hourly, want)
+	}
+
+	daily, err := m.VoteTotals(24*time.Hour, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = []VoteBucket{
+		{Start: time.Date(2024, 5, 2, 0, 0, 0, 0, time.UTC), Down: 1},
+		{Start: time.Date(2024, 5, 3, 0, 0, 0, 0, time.UTC), Up: 3, Down: 1},
+	}
+	if !reflect.DeepEqual(daily, want) {
+		t.Errorf("daily totals = %+v, want %+v", daily, want)
+	}
+
+	if _, err := m.VoteTotals(0, 3); err == nil {
+		t.Error("VoteTotals accepted an empty bucket")
+	}
+}
+
+func TestFastestToScore(t *testing.T) {
+	m := newTestManager(t)
+	saveImages(t, m, &Image{ID: "1"}, &Image{ID: "2"}, &Image{ID: "3"})
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	// 1 goes up, down and up twice, reaching 2 after half an hour
+	for i := 0; i < 4; i++ {
+		logVoteAt(t, m, start.Add(time.Duration(i)*10*time.Minute), 1, i != 1, "a")
+	}
+	for i := 0; i < 3; i++ {
+		logVoteAt(t, m, start.Add(time.Hour+time.Duration(i)*time.Minute), 2, true, "a")
+	}
+	logVoteAt(t, m, start, 3, true, "a")
+
+	im, d, ok := m.FastestToScore(2)
+	if !ok || im.ID != "2" || d != time.Minute {
+		t.Errorf("FastestToScore(2) = %v, %v, %v, want 2 in a minute", im, d, ok)
+	}
+	im, d, ok = m.FastestToScore(1)
+	if !ok || im.ID != "1" || d != 0 {
+		t.Errorf("FastestToScore(1) = %v, %v, %v, want 1 at its first vote", im, d, ok)
+	}
+	if im, _, ok := m.FastestToScore(5); ok {
+		t.Errorf("FastestToScore(5) = %v, want none", im)
+	}
+}
+
+func TestDetectSpikes(t *testing.T) {
+	m := newTestManager(t)
+	saveImages(t, m, &Image{ID: "1"}, &Image{ID: "2"}, &Image{ID: "3"})
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fixedClock(m, now)
+	for i := 0; i < 6; i++ {
+		logVoteAt(t, m, now.Add(-time.Duration(i)*time.Minute), 1, true, "v"+strconv.Itoa(i))
+	}
+	for i := 0; i < 10; i++ {
+		logVoteAt(t, m, now.Add(-time.Duration(i)*5*time.Minute), 2, true, "v"+strconv.Itoa(i))
+	}
+	for i := 0; i < 5; i++ {
+		logVoteAt(t, m, now.Add(-time.Duration(i)*time.Second), 3, false, "v"+strconv.Itoa(i))
+	}
+
+	if ids := imageIDs(m.DetectSpikes(10*time.Minute, 5)); !reflect.DeepEqual(ids, []string{"1"}) {
+		t.Errorf("DetectSpikes = %v, want [1] only", ids)
+	}
+}
+
+func TestDetectSpikesIgnoresOldBursts(t *testing.T) {
+	m := newTestManager(t)
+	saveImages(t, m, &Image{ID: "1"})
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	fixedClock(m, now)
+	for i := 0; i < 10; i++ {
+		logVoteAt(t, m, now.Add(-24*time.Hour+time.Duration(i)*time.Second), 1, true, "v"+strconv.Itoa(i))
+	}
+	logVoteAt(t, m, now, 1, true, "v10")
+
+	if rs := m.DetectSpikes(10*time.Minute, 5); len(rs) != 0 {
+		t.Errorf("DetectSpikes = %v, want a burst from yesterday ignored", imageIDs(rs))
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// ImageVotes and VoteCounts are the Go types of the messages defined in votes.proto. They are written by hand
+// rather than generated by protoc-gen-go, and encode themselves in the protobuf wire format, so that exporting
+// three fields doesn't add google.golang.org/protobuf to the dependencies of the app. Code generated from
+// votes.proto reads their output unchanged.
+
+// ImageVotes is the vote counts of a single image.
+type ImageVotes struct {
+	Id        string
+	UpVotes   int64
+	DownVotes int64
+}
+
+// VoteCounts is the vote counts of the whole catalog.
+type VoteCounts struct {
+	Images []*ImageVotes
+}
+
+// The wire types used by the messages.
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+var errTruncatedProto = errors.New("truncated protobuf message")
+
+// VotesProto serializes the id and vote counts of every image as a VoteCounts message.
+func (m *ImageManager) VotesProto() ([]byte, error) {
+	var counts VoteCounts
+	for _, im := range m.All() {
+		counts.Images = append(counts.Images, &ImageVotes{Id: im.ID, UpVotes: int64(im.UpVotes), DownVotes: int64(im.DownVotes)})
+	}
+
+	return counts.Marshal()
+}
+
+// Marshal returns the wire encoding of the message.
+func (c *VoteCounts) Marshal() ([]byte, error) {
+	var b []byte
+	for _, im := range c.Images {
+		msg, err := im.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		b = appendBytesField(b, 1, msg)
+	}
+	return b, nil
+}
+
+// Unmarshal decodes the wire encoding of the message into c.
+func (c *VoteCounts) Unmarshal(b []byte) error {
+	c.Images = nil
+	return parseFields(b, func(num int, wire int, v uint64, data []byte) error {
+		if num == 1 && wire == wireBytes {
+			im := new(ImageVotes)
+			if err := im.Unmarshal(data); err != nil {
+				return err
+			}
+			c.Images = append(c.Images, im)
+		}
+		return nil
+	})
+}
+
+// Marshal returns the wire encoding of the message. Fields holding their zero value are omitted, as in proto3.
+func (v *ImageVotes) Marshal() ([]byte, error) {
+	var b []byte
+	if v.Id != "" {
+		b = appendBytesField(b, 1, []byte(v.Id))
+	}
+	if v.UpVotes != 0 {
+		b = appendVarintField(b, 2, uint64(v.UpVotes))
+	}
+	if v.DownVotes != 0 {
+		b = appendVarintField(b, 3, uint64(v.DownVotes))
+	}
+	return b, nil
+}
+
+// Unmarshal decodes the wire encoding of the message into v.
+func (v *ImageVotes) Unmarshal(b []byte) error {
+	*v = ImageVotes{}
+	return parseFields(b, func(num int, wire int, n uint64, data []byte) error {
+		switch {
+		case num == 1 && wire == wireBytes:
+			v.Id = string(data)
+		case num == 2 && wire == wireVarint:
+			v.UpVotes = int64(n)
+		case num == 3 && wire == wireVarint:
+			v.DownVotes = int64(n)
+		}
+		return nil
+	})
+}
+
+func appendVarintField(b []byte, num int, v uint64) []byte {
+	b = binary.AppendUvarint(b, uint64(num)<<3|wireVarint)
+	return binary.AppendUvarint(b, v)
+}
+
+func appendBytesField(b []byte, num int, data []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(num)<<3|wireBytes)
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
+
+// parseFields calls field for every field of the encoded message, with its varint value or its bytes
+// depending on the wire type. Unknown fields are passed along too and left to field to skip.
+func parseFields(b []byte, field func(num int, wire int, v uint64, data []byte) error) error {
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return errTruncatedProto
+		}
+		b = b[n:]
+
+		num, wire := int(key>>3), int(key&7)
+		var v uint64
+		var data []byte
+		switch wire {
+		case wireVarint:
+			v, n = binary.Uvarint(b)
+			if n <= 0 {
+				return errTruncatedProto
+			}
+			b = b[n:]
+		case wireBytes:
+			size, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < size {
+				return errTruncatedProto
+			}
+			data = b[n : n+int(size)]
+			b = b[n+int(size):]
+		default:
+			return fmt.Errorf("unsupported protobuf wire type %d", wire)
+		}
+
+		if err := field(num, wire, v, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestVotesProtoRoundTrip(t *testing.T) {
+	m := newCatalog(t, &Image{ID: "1", UpVotes: 2, DownVotes: 1}, &Image{ID: "2"}, &Image{ID: "3", UpVotes: 300})
+
+	b, err := m.VotesProto()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var counts VoteCounts
+	if err := counts.Unmarshal(b); err != nil {
+		t.Fatal(err)
+	}
+	want := []*ImageVotes{{"1", 2, 1}, {"2", 0, 0}, {"3", 300, 0}}
+	if !reflect.DeepEqual(counts.Images, want) {
+		t.Errorf("decoded = %+v, want %+v", counts.Images, want)
+	}
+}
+
+func TestVotesProtoWireFormat(t *testing.T) {
+	b, err := (&VoteCounts{Images: []*ImageVotes{{Id: "1", UpVotes: 2}}}).Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// images: field 1, 5 bytes holding id: field 1 "1" and up_votes: field 2 varint 2
+	if want := []byte{0x0a, 0x05, 0x0a, 0x01, '1', 0x10, 0x02}; !bytes.Equal(b, want) {
+		t.Errorf("Marshal = % x, want % x", b, want)
+	}
+
+	var counts VoteCounts
+	if err := counts.Unmarshal(b[:4]); !errors.Is(err, errTruncatedProto) {
+		t.Errorf("Unmarshal of a truncated message error = %v, want errTruncatedProto", err)
+	}
+}
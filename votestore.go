@@ -0,0 +1,325 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// VoteStore persists the puppies along with their vote counts.
+type VoteStore interface {
+	// Load returns the stored puppies with the given ids. Unknown ids are skipped.
+	Load(ids []string) ([]*Image, error)
+
+	// Save stores new puppies along with their current vote counts.
+	Save(images []*Image) error
+
+	// Increment atomically adds an up or a down vote to the stored puppy with the given id, so that concurrent
+	// writers never lose a vote. It reports whether the puppy was found, and returns its new vote counts if so.
+	Increment(id string, up bool) (VoteCount, bool, error)
+
+	// Decrement removes an up or a down vote from the stored puppy with the given id.
+	// It reports whether a vote was removed, which isn't the case when the puppy has no such vote or is unknown.
+	Decrement(id string, up bool) (bool, error)
+
+	// Set overwrites the vote counts of the stored puppies, all at once. When one of the puppies is unknown
+	// it stores nothing and returns an error wrapping ErrImageNotFound.
+	Set(counts ...VoteCount) error
+
+	// LoadAll returns all the stored puppies, along with their impressions and expiry.
+	LoadAll() ([]*Image, error)
+
+	// Top returns limit stored puppies ranked by up votes, then by id, skipping the first offset ones.
+	// The puppies expired at now are left out.
+	Top(offset, limit int, now time.Time) ([]*Image, error)
+
+	// Count returns the number of stored puppies.
+	Count() (int, error)
+
+	// CountVisible returns the number of stored puppies not expired at now.
+	CountVisible(now time.Time) (int, error)
+
+	// SetExpiry stores when the puppy with the given id stops being visible, the zero time meaning never.
+	// It reports whether the puppy was found.
+	SetExpiry(id string, t time.Time) (bool, error)
+
+	// Delete removes the stored puppies with the given ids, returning the ids of the ones which were found.
+	Delete(ids []string) ([]string, error)
+
+	// AddImpressions adds an impression to every stored puppy with the given id, once per occurrence of the id.
+	AddImpressions(ids []string) error
+}
+
+// VoteCount holds the up and down votes of the puppy with the given id.
+type VoteCount struct {
+	ID                 string
+	UpVotes, DownVotes int
+}
+
+// SQLiteVoteStore is the VoteStore keeping the puppies in the votes table of a SQLite database.
+// Its methods return an error when DB is nil.
+type SQLiteVoteStore struct {
+	DB *sql.DB
+
+	// SlowQueryThreshold and Logger log the slow statements, as for an ImageManager.
+	SlowQueryThreshold time.Duration
+	Logger             *log.Logger
+}
+
+// db returns the database of the store, timed as configured.
+func (s *SQLiteVoteStore) db() timedDB {
+	return timedDB{s.DB, queryTimer{s.SlowQueryThreshold, s.Logger}}
+}
+
+func (s *SQLiteVoteStore) Load(ids []string) ([]*Image, error) {
+	query := fmt.Sprintf("select * from votes where puppy_id in (%s)",
+		strings.Join(strings.Split(strings.Repeat("?", len(ids)), ""), ","))
+
+	stmt, err := s.db().Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	var params []interface{}
+	for _, id := range ids {
+		params = append(params, id)
+	}
+
+	rows, err := stmt.Query(params...)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanVotes(rows, false)
+}
+
+// scanVotes reads and closes rows of the votes table, followed by the impressions and the expiry of the puppy
+// if withExtras.
+func scanVotes(rows *sql.Rows, withExtras bool) ([]*Image, error) {
+	defer rows.Close()
+
+	var rs []*Image
+	for rows.Next() {
+		var dbImage Image
+		var id int
+		var expiresAt int64
+		dest := []interface{}{&id, &dbImage.ID, &dbImage.Title, &dbImage.Thumbnail, &dbImage.Large, &dbImage.UpVotes, &dbImage.DownVotes}
+		if withExtras {
+			dest = append(dest, &dbImage.Impressions, &expiresAt)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		if expiresAt != 0 {
+			dbImage.ExpiresAt = time.Unix(expiresAt, 0)
+		}
+		rs = append(rs, &dbImage)
+	}
+
+	return rs, rows.Err()
+}
+
+func (s *SQLiteVoteStore) Save(images []*Image) error {
+	tx, err := s.db().Begin()
+	if err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare("insert into votes(puppy_id, title, thumbnail, large, up_votes, down_votes) values(?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	defer stmt.Close()
+
+	for _, im := range images {
+		if _, err := stmt.Exec(im.ID, im.Title, im.Thumbnail, im.Large, im.UpVotes, im.DownVotes); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteVoteStore) Increment(id string, up bool) (VoteCount, bool, error) {
+	sqlStmt := "update votes set "
+	if up {
+		sqlStmt += " up_votes = up_votes + 1"
+	} else {
+		sqlStmt += " down_votes = down_votes + 1"
+	}
+	sqlStmt += " where puppy_id = ? returning up_votes, down_votes"
+
+	counts := VoteCount{ID: id}
+	err := s.db().QueryRow(sqlStmt, id).Scan(&counts.UpVotes, &counts.DownVotes)
+	if err == sql.ErrNoRows {
+		return counts, false, nil
+	}
+	return counts, err == nil, err
+}
+
+func (s *SQLiteVoteStore) Decrement(id string, up bool) (bool, error) {
+	sqlStmt := "update votes set up_votes = up_votes - 1 where puppy_id = ? and up_votes > 0"
+	if !up {
+		sqlStmt = "update votes set down_votes = down_votes - 1 where puppy_id = ? and down_votes > 0"
+	}
+
+	res, err := s.db().Exec(sqlStmt, id)
+	if err != nil {
+		return false, err
+	}
+
+	affect, err := res.RowsAffected()
+	return affect > 0, err
+}
+
+func (s *SQLiteVoteStore) Set(counts ...VoteCount) error {
+	tx, err := s.db().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("update votes set up_votes = ?, down_votes = ? where puppy_id = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, c := range counts {
+		res, err := stmt.Exec(c.UpVotes, c.DownVotes, c.ID)
+		if err != nil {
+			return err
+		}
+
+		affect, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affect == 0 {
+			return fmt.Errorf("setting votes of %s: %w", c.ID, ErrImageNotFound)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLiteVoteStore) LoadAll() ([]*Image, error) {
+	rows, err := s.db().Query(`select v.*, coalesce(i.count, 0), coalesce(e.expires_at, 0) from votes v
+		left join impressions i on i.puppy_id = v.puppy_id left join expiries e on e.puppy_id = v.puppy_id`)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanVotes(rows, true)
+}
+
+// expiredPuppies selects the ids of the puppies expired at the time given as its parameter.
+const expiredPuppies = "select puppy_id from expiries where expires_at != 0 and expires_at <= ?"
+
+func (s *SQLiteVoteStore) Top(offset, limit int, now time.Time) ([]*Image, error) {
+	rows, err := s.db().Query("select * from votes where puppy_id not in ("+expiredPuppies+") order by up_votes desc, puppy_id limit ?,?",
+		now.Unix(), offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanVotes(rows, false)
+}
+
+func (s *SQLiteVoteStore) Count() (int, error) {
+	var count int
+	err := s.db().QueryRow("select count(id) from votes").Scan(&count)
+	return count, err
+}
+
+func (s *SQLiteVoteStore) CountVisible(now time.Time) (int, error) {
+	var count int
+	err := s.db().QueryRow("select count(id) from votes where puppy_id not in ("+expiredPuppies+")", now.Unix()).Scan(&count)
+	return count, err
+}
+
+func (s *SQLiteVoteStore) SetExpiry(id string, t time.Time) (bool, error) {
+	var expiresAt int64
+	if !t.IsZero() {
+		expiresAt = t.Unix()
+	}
+
+	res, err := s.db().Exec("insert or replace into expiries(puppy_id, expires_at) select puppy_id, ? from votes where puppy_id = ?", expiresAt, id)
+	if err != nil {
+		return false, err
+	}
+	affect, err := res.RowsAffected()
+	return affect > 0, err
+}
+
+func (s *SQLiteVoteStore) Delete(ids []string) ([]string, error) {
+	tx, err := s.db().Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("delete from votes where puppy_id = ?")
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	expiry, err := tx.Prepare("delete from expiries where puppy_id = ?")
+	if err != nil {
+		return nil, err
+	}
+	defer expiry.Close()
+
+	var deleted []string
+	for _, id := range ids {
+		res, err := stmt.Exec(id)
+		if err != nil {
+			return nil, err
+		}
+		if affect, _ := res.RowsAffected(); affect > 0 {
+			deleted = append(deleted, id)
+		}
+		if _, err := expiry.Exec(id); err != nil {
+			return nil, err
+		}
+	}
+
+	return deleted, tx.Commit()
+}
+
+func (s *SQLiteVoteStore) AddImpressions(ids []string) error {
+	tx, err := s.db().Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insert, err := tx.Prepare("insert or ignore into impressions(puppy_id, count) values(?, 0)")
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+
+	update, err := tx.Prepare("update impressions set count = count + 1 where puppy_id = ?")
+	if err != nil {
+		return err
+	}
+	defer update.Close()
+
+	for _, id := range ids {
+		if _, err := insert.Exec(id); err != nil {
+			return err
+		}
+		if _, err := update.Exec(id); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeVoteStore is a VoteStore keeping the puppies in memory, taking delay to run any call.
+type fakeVoteStore struct {
+	mu     sync.Mutex
+	delay  time.Duration
+	images map[string]*Image
+	calls  []string
+}
+
+func newFakeVoteStore(images ...*Image) *fakeVoteStore {
+	s := &fakeVoteStore{images: make(map[string]*Image)}
+	for _, im := range images {
+		s.images[im.ID] = cloneImage(im)
+	}
+	return s
+}
+
+// call sleeps for the delay of the store, as a database would take to answer, and then locks the store
+// and records the call. The caller must unlock the store.
+func (s *fakeVoteStore) call(format string, args ...interface{}) {
+	time.Sleep(s.delay)
+	s.mu.Lock()
+	s.calls = append(s.calls, fmt.Sprintf(format, args...))
+}
+
+func (s *fakeVoteStore) Load(ids []string) ([]*Image, error) {
+	defer s.mu.Unlock()
+	s.call("Load %v", ids)
+
+	var rs []*Image
+	for _, id := range ids {
+		if im, ok := s.images[id]; ok {
+			rs = append(rs, cloneImage(im))
+		}
+	}
+	return rs, nil
+}
+
+func (s *fakeVoteStore) Save(images []*Image) error {
+	defer s.mu.Unlock()
+	s.call("Save %v", imageIDs(images))
+
+	for _, im := range images {
+		s.images[im.ID] = cloneImage(im)
+	}
+	return nil
+}
+
+func (s *fakeVoteStore) Increment(id string, up bool) (VoteCount, bool, error) {
+	defer s.mu.Unlock()
+	s.call("Increment %s %v", id, up)
+
+	im, ok := s.images[id]
+	if !ok {
+		return VoteCount{ID: id}, false, nil
+	}
+	if up {
+		im.UpVotes++
+	} else {
+		im.DownVotes++
+	}
+	return VoteCount{id, im.UpVotes, im.DownVotes}, true, nil
+}
+
+func (s *fakeVoteStore) Decrement(id string, up bool) (bool, error) {
+	defer s.mu.Unlock()
+	s.call("Decrement %s %v", id, up)
+
+	im, ok := s.images[id]
+	if !ok {
+		return false, nil
+	}
+	if up && im.UpVotes > 0 {
+		im.UpVotes--
+		return true, nil
+	}
+	if !up && im.DownVotes > 0 {
+		im.DownVotes--
+		return true, nil
+	}
+	return false, nil
+}
+
+func (s *fakeVoteStore) Set(counts ...VoteCount) error {
+	defer s.mu.Unlock()
+	s.call("Set %v", counts)
+
+	for _, c := range counts {
+		if _, ok := s.images[c.ID]; !ok {
+			return fmt.Errorf("setting votes of %s: %w", c.ID, ErrImageNotFound)
+		}
+	}
+	for _, c := range counts {
+		s.images[c.ID].UpVotes = c.UpVotes
+		s.images[c.ID].DownVotes = c.DownVotes
+	}
+	return nil
+}
+
+func (s *fakeVoteStore) LoadAll() ([]*Image, error) {
+	defer s.mu.Unlock()
+	s.call("LoadAll")
+
+	var rs []*Image
+	for _, im := range s.images {
+		rs = append(rs, cloneImage(im))
+	}
+	sort.Slice(rs, func(i, j int) bool { return rs[i].ID < rs[j].ID })
+	return rs, nil
+}
+
+func (s *fakeVoteStore) Top(offset, limit int, now time.Time) ([]*Image, error) {
+	defer s.mu.Unlock()
+	s.call("Top %d %d", offset, limit)
+
+	var rs []*Image
+	for _, im := range s.images {
+		if im.visibleAt(now) {
+			rs = append(rs, cloneImage(im))
+		}
+	}
+	sort.Slice(rs, func(i, j int) bool {
+		if rs[i].UpVotes != rs[j].UpVotes {
+			return rs[i].UpVotes > rs[j].UpVotes
+		}
+		return rs[i].ID < rs[j].ID
+	})
+	if offset > len(rs) {
+		offset = len(rs)
+	}
+	return firstN(rs[offset:], limit), nil
+}
+
+func (s *fakeVoteStore) Count() (int, error) {
+	defer s.mu.Unlock()
+	s.call("Count")
+
+	return len(s.images), nil
+}
+
+func (s *fakeVoteStore) CountVisible(now time.Time) (int, error) {
+	defer s.mu.Unlock()
+	s.call("CountVisible")
+
+	var count int
+	for _, im := range s.images {
+		if im.visibleAt(now) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (s *fakeVoteStore) SetExpiry(id string, t time.Time) (bool, error) {
+	defer s.mu.Unlock()
+	s.call("SetExpiry %s", id)
+
+	im, ok := s.images[id]
+	if ok {
+		im.ExpiresAt = t
+	}
+	return ok, nil
+}
+
+func (s *fakeVoteStore) Delete(ids []string) ([]string, error) {
+	defer s.mu.Unlock()
+	s.call("Delete %v", ids)
+
+	var deleted []string
+	for _, id := range ids {
+		if _, ok := s.images[id]; ok {
+			delete(s.images, id)
+			deleted = append(deleted, id)
+		}
+	}
+	return deleted, nil
+}
+
+func (s *fakeVoteStore) AddImpressions(ids []string) error {
+	defer s.mu.Unlock()
+	s.call("AddImpressions %v", ids)
+
+	for _, id := range ids {
+		if im, ok := s.images[id]; ok {
+			im.Impressions++
+		}
+	}
+	return nil
+}
+
+func TestManagerUsesVoteStore(t *testing.T) {
+	store := newFakeVoteStore(&Image{ID: "1", UpVotes: 1}, &Image{ID: "2", UpVotes: 3}, &Image{ID: "3"})
+	m := NewImageManager()
+	m.Votes = store
+
+	if err := m.LoadImages(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.PersistVote("1", true); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.RecordImpressions([]string{"1", "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ImportCSV(strings.NewReader("2,5,1\n")); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := m.DeleteMany([]string{"3"}); err != nil || n != 1 {
+		t.Fatalf("DeleteMany = %d, %v, want 1", n, err)
+	}
+	if mem, stored, err := m.Counts(); err != nil || mem != 2 || stored != 2 {
+		t.Errorf("Counts = %d, %d, %v, want 2, 2", mem, stored, err)
+	}
+	if top := m.GetPuppiesByMostVotes(1); len(top) != 2 || top[0].ID != "2" {
+		t.Errorf("GetPuppiesByMostVotes = %v, want 2 first", imageIDs(top))
+	}
+
+	want := []string{
+		"LoadAll",
+		"Increment 1 true",
+		"AddImpressions [1 2]",
+		"Set [{2 5 1}]",
+		"Delete [3]",
+		"Count",
+		"Top 0 10",
+	}
+	if !reflect.DeepEqual(store.calls, want) {
+		t.Errorf("calls = %q, want %q", store.calls, want)
+	}
+
+	stored, _ := store.Load([]string{"1", "2"})
+	if len(stored) != 2 {
+		t.Fatalf("stored = %v, want 1 and 2", imageIDs(stored))
+	}
+	if stored[0].UpVotes != 2 || stored[0].Impressions != 1 || stored[1].UpVotes != 5 || stored[1].DownVotes != 1 {
+		t.Errorf("stored = %+v, %+v", *stored[0], *stored[1])
+	}
+}
+
+func TestSQLiteVoteStore(t *testing.T) {
+	m := newTestManager(t)
+	store := &SQLiteVoteStore{DB: m.GetDB()}
+
+	if err := store.Save([]*Image{{ID: "1", UpVotes: 1}, {ID: "2", UpVotes: 4}, {ID: "3", UpVotes: 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set(VoteCount{"1", 6, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set(VoteCount{"3", 9, 9}, VoteCount{"4", 1, 1}); !errors.Is(err, ErrImageNotFound) {
+		t.Errorf("Set of an unknown puppy error = %v, want ErrImageNotFound", err)
+	}
+	if err := store.AddImpressions([]string{"2", "2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	top, err := store.Top(1, 5, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := imageIDs(top); !reflect.DeepEqual(ids, []string{"2", "3"}) {
+		t.Errorf("Top = %v, want [2 3], the puppy 3 being left alone by the failed Set", ids)
+	}
+
+	deleted, err := store.Delete([]string{"3", "4"})
+	if err != nil || !reflect.DeepEqual(deleted, []string{"3"}) {
+		t.Errorf("Delete = %v, %v, want [3]", deleted, err)
+	}
+	if n, err := store.Count(); err != nil || n != 2 {
+		t.Errorf("Count = %d, %v, want 2", n, err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	if found, err := store.SetExpiry("1", now); err != nil || !found {
+		t.Errorf("SetExpiry = %v, %v, want the puppy found", found, err)
+	}
+	if found, err := store.SetExpiry("4", now); err != nil || found {
+		t.Errorf("SetExpiry of an unknown puppy = %v, %v, want not found", found, err)
+	}
+	if top, err := store.Top(0, 5, now); err != nil || !reflect.DeepEqual(imageIDs(top), []string{"2"}) {
+		t.Errorf("Top at the expiry = %v, %v, want [2]", imageIDs(top), err)
+	}
+	if n, err := store.CountVisible(now.Add(-time.Second)); err != nil || n != 2 {
+		t.Errorf("CountVisible before the expiry = %d, %v, want 2", n, err)
+	}
+	if n, err := store.CountVisible(now); err != nil || n != 1 {
+		t.Errorf("CountVisible at the expiry = %d, %v, want 1", n, err)
+	}
+
+	all, err := store.LoadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("LoadAll = %v, want 2 puppies", imageIDs(all))
+	}
+	if all[0].UpVotes != 6 || all[0].DownVotes != 2 || !all[0].ExpiresAt.Equal(now) || all[1].Impressions != 2 {
+		t.Errorf("LoadAll = %+v, %+v", *all[0], *all[1])
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// milestonePayload is the JSON posted to the WebhookURL when an image reaches a vote milestone.
+type milestonePayload struct {
+	ID        string `json:"id"`
+	UpVotes   int    `json:"upvotes"`
+	Milestone int    `json:"milestone"`
+}
+
+// httpClient returns the client the ImageManager sends its requests with.
+func (m *ImageManager) httpClient() *http.Client {
+	if m.HTTPClient != nil {
+		return m.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// upVoted notifies the WebhookURL, without waiting for it, when the up vote taking the image with the given id
+// to upVotes reaches one of the VoteMilestones.
+func (m *ImageManager) upVoted(id string, upVotes int) {
+	if m.WebhookURL == "" {
+		return
+	}
+
+	for _, milestone := range m.VoteMilestones {
+		if upVotes != milestone {
+			continue
+		}
+
+		body, err := json.Marshal(milestonePayload{id, upVotes, milestone})
+		if err != nil {
+			log.Println(err)
+			return
+		}
+
+		go func() {
+			resp, err := m.httpClient().Post(m.WebhookURL, "application/json", bytes.NewReader(body))
+			if err != nil {
+				log.Println(err)
+				return
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= 300 {
+				log.Println(fmt.Errorf("milestone webhook: %s", resp.Status))
+			}
+		}()
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestMilestoneWebhookFiresOnce(t *testing.T) {
+	posts := make(chan milestonePayload, 10)
+	m := NewImageManager()
+	m.WebhookURL = "https://hooks.example.com/puppies"
+	m.VoteMilestones = []int{3, 10}
+	m.HTTPClient = stubClient(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload milestonePayload
+		if r.Method != "POST" || r.URL.String() != m.WebhookURL || json.NewDecoder(r.Body).Decode(&payload) != nil {
+			t.Errorf("webhook request = %s %s, want a JSON POST to the WebhookURL", r.Method, r.URL)
+		}
+		posts <- payload
+	}))
+	saveImages(t, m, &Image{ID: "1"})
+
+	for i := 0; i < 5; i++ {
+		if _, _, err := m.Update(&Image{ID: "1"}, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, _, err := m.Update(&Image{ID: "1"}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case payload := <-posts:
+		if payload != (milestonePayload{"1", 3, 3}) {
+			t.Errorf("payload = %+v, want image 1 reaching 3 up votes", payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the webhook was not called")
+	}
+
+	select {
+	case payload := <-posts:
+		t.Errorf("webhook called again with %+v", payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+}